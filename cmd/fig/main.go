@@ -34,7 +34,7 @@ func main() {
 	unauthorized := func(r *http.Request) server.Store {
 		return nil
 	}
-	authStore := cache.New(store, time.Second, nil)
+	authStore := cache.NewBackground(store, time.Second)
 
 	authorize := server.BasicAuth(authStore, authorized, unauthorized)
 	handler := server.Handler(authorize)
@@ -42,6 +42,9 @@ func main() {
 	http.Handle("/", http.FileServer(http.Dir(*staticDir)))
 	http.Handle("/items", handler)
 	http.Handle("/items/", handler)
+	http.Handle("/exposures", handler)
+	http.Handle("/exposures/", handler)
+	http.Handle("/eval/", handler)
 
 	log.Fatal(http.ListenAndServe(*address, nil))
 }
@@ -2,6 +2,8 @@
 package cache
 
 import (
+	"fmt"
+	"math/rand"
 	"sync"
 	"time"
 )
@@ -28,48 +30,216 @@ type Store interface {
 //
 // The cache is updated atmost every refresh interval. Set() and History()
 // are not cached. Only GetSince is cached
-func New(s Store, refresh time.Duration, now func() time.Time) Store {
+//
+// The update happens within the GetSince call that finds the cache
+// stale.  Concurrent callers do not wait for it: they get the
+// previous config instead.  If the update fails, the last fetched
+// config continues to be served and the next update is attempted
+// with exponential backoff.  GetSince only panics if no config has
+// ever been fetched.
+func New(s Store, refresh time.Duration, now func() time.Time) *Cache {
 	n := time.Now
 	if now != nil {
 		n = now
 	}
-	return &cache{s, refresh, -1, nil, time.Time{}, n, sync.Mutex{}}
+	return &Cache{Store: s, refresh: refresh, now: n, ver: -1}
+}
+
+// NewBackground wraps a store with a cache that is refreshed by a
+// background goroutine.
+//
+// The refresh interval is jittered by upto 10% to avoid many
+// clients hitting the server at the same time.  Failures are
+// retried with exponential backoff while the last fetched config
+// continues to be served.  GetSince does not fetch anything except
+// when no config has been fetched yet.
+//
+// Close must be called to stop the background goroutine.
+func NewBackground(s Store, refresh time.Duration) *Cache {
+	c := New(s, refresh, nil)
+	c.background = true
+	c.done = make(chan struct{})
+	c.wg.Add(1)
+	go c.run()
+	return c
 }
 
-type cache struct {
+// Cache is a store whose GetSince calls are cached.
+//
+// Use New or NewBackground to create a cache.
+type Cache struct {
 	Store
-	refresh       time.Duration
-	ver           int
-	config        map[string]string
-	lastRefreshed time.Time
-	now           func() time.Time
+	refresh    time.Duration
+	now        func() time.Time
+	background bool
+
+	fetch sync.Mutex // held while fetching from the store
+
 	sync.Mutex
+	ver      int
+	config   map[string]string
+	loaded   bool
+	fetching bool
+	next     time.Time
+	failures int
+	err      error
+
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+
+	watchers watchers
 }
 
-func (c *cache) GetSince(version int) (newVersion int, configs map[string]string) {
+// maxBackoff limits the backoff on failures to a multiple of the
+// refresh interval
+const maxBackoff = 64
+
+func (c *Cache) GetSince(version int) (newVersion int, configs map[string]string) {
 	c.Lock()
 	if version > 0 && version != c.ver {
-		defer c.Unlock()
+		c.Unlock()
 		return c.Store.GetSince(version)
 	}
+	due := !c.now().Before(c.next) && (!c.background || !c.loaded)
+	c.Unlock()
+
+	if due {
+		c.update(false)
+	}
+
+	c.Lock()
 	defer c.Unlock()
+	if !c.loaded {
+		panic(c.err)
+	}
+	return c.ver, c.config
+}
 
-	if c.lastRefreshed.Add(c.refresh).After(c.now()) {
-		return c.ver, c.config
+// Err returns the error from the last attempt to update the cache
+// or nil if it succeeded.
+func (c *Cache) Err() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.err
+}
+
+// Close stops the background refresh, if any, as well as the
+// delivery of change notifications.
+func (c *Cache) Close() error {
+	if c.background {
+		c.closeOnce.Do(func() { close(c.done) })
+		c.wg.Wait()
+	}
+	c.watchers.close()
+	return nil
+}
+
+func (c *Cache) run() {
+	defer c.wg.Done()
+
+	for {
+		c.Lock()
+		wait := c.next.Sub(c.now())
+		c.Unlock()
+
+		if wait < 0 {
+			wait = 0
+		}
+		wait += time.Duration((rand.Float64()*2 - 1) * 0.1 * float64(wait))
+		timer := time.NewTimer(wait)
+		select {
+		case <-c.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		c.update(true)
+	}
+}
+
+// update fetches changes from the underlying store.
+//
+// Only one fetch is in progress at a time. Callers which already
+// have a config to serve do not wait for an in-progress fetch.
+func (c *Cache) update(force bool) {
+	c.Lock()
+	if c.fetching && c.loaded {
+		c.Unlock()
+		return
+	}
+	c.Unlock()
+
+	c.fetch.Lock()
+	defer c.fetch.Unlock()
+
+	c.Lock()
+	if !force && c.now().Before(c.next) {
+		// some other caller updated it already
+		c.Unlock()
+		return
+	}
+	c.fetching = true
+	version := c.ver
+	c.Unlock()
+
+	ver, next, err := Fetch(c.Store, version)
+
+	c.Lock()
+	defer c.Unlock()
+	c.fetching = false
+	c.err = err
+	if err != nil {
+		c.failures++
+		backoff := maxBackoff
+		if c.failures < 6 {
+			backoff = 1 << uint(c.failures)
+		}
+		c.next = c.now().Add(time.Duration(backoff) * c.refresh)
+		return
 	}
 
-	ver, next := c.Store.GetSince(c.ver)
 	result := map[string]string{}
 	for k, v := range c.config {
 		result[k] = v
 	}
 
+	old, changed := map[string]string{}, map[string]string{}
 	for k, v := range next {
+		if prev, ok := result[k]; ok {
+			if prev == v {
+				continue
+			}
+			old[k] = prev
+		}
+		changed[k] = v
 		result[k] = v
 	}
 
+	if len(changed) > 0 {
+		c.watchers.notify(change{ver, old, changed})
+	}
+
 	c.config = result
 	c.ver = ver
-	c.lastRefreshed = c.now()
-	return ver, result
+	c.loaded = true
+	c.failures = 0
+	c.next = c.now().Add(c.refresh)
+}
+
+// Fetch calls s.GetSince, converting panics (such as those of the
+// fig client on network errors) into errors
+func Fetch(s Store, version int) (ver int, configs map[string]string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	ver, configs = s.GetSince(version)
+	return ver, configs, nil
 }
@@ -53,3 +53,187 @@ func TestCache(t *testing.T) {
 		t.Fatal("Unexpected pass throgh", x, cfg)
 	}
 }
+
+func TestCacheStaleOnError(t *testing.T) {
+	fakeTime := time.Now()
+	now := func() time.Time { return fakeTime }
+	duration := 5 * time.Second
+
+	s := &flaky{}
+	c := cache.New(s, duration, now)
+
+	s.fail = true
+	mustPanic(t, "initial fetch", func() { c.GetSince(-1) })
+	if c.Err() == nil {
+		t.Fatal("Unexpected missing error")
+	}
+
+	// retries must back off
+	fakeTime = fakeTime.Add(duration)
+	s.fail = false
+	mustPanic(t, "backoff", func() { c.GetSince(-1) })
+
+	fakeTime = fakeTime.Add(duration)
+	if ver, config := c.GetSince(-1); ver != 1 || config["boo"] != "hoo" {
+		t.Fatal("Unexpected config", ver, config)
+	}
+	if c.Err() != nil {
+		t.Fatal("Unexpected error", c.Err())
+	}
+
+	// failures now serve the last known good config
+	s.fail = true
+	fakeTime = fakeTime.Add(duration)
+	if ver, config := c.GetSince(-1); ver != 1 || config["boo"] != "hoo" {
+		t.Fatal("Unexpected config", ver, config)
+	}
+	if c.Err() == nil || s.calls != 3 {
+		t.Fatal("Unexpected refresh", c.Err(), s.calls)
+	}
+
+	s.fail = false
+	fakeTime = fakeTime.Add(duration)
+	if ver, config := c.GetSince(-1); ver != 1 || s.calls != 3 {
+		t.Fatal("Unexpected refresh during backoff", ver, config, s.calls)
+	}
+	fakeTime = fakeTime.Add(duration)
+	if ver, config := c.GetSince(-1); ver != 2 || config["boo"] != "hoo" || s.calls != 4 {
+		t.Fatal("Unexpected config", ver, config, s.calls)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal("Unexpected close error", err)
+	}
+}
+
+func TestCacheBackground(t *testing.T) {
+	redis, err := miniredis.Run()
+	if err != nil {
+		t.Fatal("mini redis failed", err)
+	}
+	defer redis.Close()
+
+	s := server.NewRedisStore(redis.Addr(), "test-redis")
+	c := cache.NewBackground(s, time.Millisecond)
+
+	s.Set("boo", `"hoo"`)
+	for {
+		if ver, _ := c.GetSince(-1); ver == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatal("Unexpected close error", err)
+	}
+
+	// no more refreshes after close
+	s.Set("boo", `"woo"`)
+	time.Sleep(5 * time.Millisecond)
+	if ver, config := c.GetSince(-1); ver != 1 || config["boo"] != `"hoo"` {
+		t.Fatal("Unexpected config", ver, config)
+	}
+
+	// without a cached copy, the initial fetch fails during an outage
+	redis.Close()
+	c = cache.NewBackground(s, time.Millisecond)
+	defer c.Close()
+	mustPanic(t, "initial fetch", func() { c.GetSince(-1) })
+}
+
+// flaky is a store that fails on demand.  Every successful fetch
+// bumps the version
+type flaky struct {
+	fail       bool
+	calls, ver int
+	value      string
+	cache.Store
+}
+
+func (f *flaky) GetSince(version int) (int, map[string]string) {
+	f.calls++
+	if f.fail {
+		panic("server down")
+	}
+	f.ver++
+	if f.value == "" {
+		return f.ver, map[string]string{"boo": "hoo"}
+	}
+	return f.ver, map[string]string{"boo": f.value}
+}
+
+func TestFetch(t *testing.T) {
+	s := &flaky{}
+	if ver, configs, err := cache.Fetch(s, -1); ver != 1 || configs["boo"] != "hoo" || err != nil {
+		t.Error("Unexpected fetch", ver, configs, err)
+	}
+
+	s.fail = true
+	if _, _, err := cache.Fetch(s, -1); err == nil || err.Error() != "server down" {
+		t.Error("Unexpected error", err)
+	}
+}
+
+func mustPanic(t *testing.T, cause string, fn func()) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("did not panic:", cause)
+		}
+	}()
+	fn()
+}
+
+func TestCacheWatch(t *testing.T) {
+	fakeTime := time.Now()
+	now := func() time.Time { return fakeTime }
+	duration := 5 * time.Second
+
+	s := &flaky{}
+	c := cache.New(s, duration, now)
+	defer c.Close()
+
+	type event struct {
+		version  int
+		old, new string
+	}
+	events := make(chan event, 100)
+	c.Watch("boo", func(old, new string) {
+		events <- event{-1, old, new}
+	})
+	cancel := c.OnChange(func(version int, old, new map[string]string) {
+		events <- event{version, old["boo"], new["boo"]}
+	})
+
+	if ver, _ := c.GetSince(-1); ver != 1 {
+		t.Fatal("Unexpected version", ver)
+	}
+	if e := <-events; e != (event{-1, "", "hoo"}) {
+		t.Fatal("Unexpected event", e)
+	}
+	if e := <-events; e != (event{1, "", "hoo"}) {
+		t.Fatal("Unexpected event", e)
+	}
+
+	// unchanged values are not reported
+	fakeTime = fakeTime.Add(duration)
+	if ver, _ := c.GetSince(-1); ver != 2 {
+		t.Fatal("Unexpected version", ver)
+	}
+
+	cancel()
+	s.value = "woo"
+	fakeTime = fakeTime.Add(duration)
+	if ver, _ := c.GetSince(-1); ver != 3 {
+		t.Fatal("Unexpected version", ver)
+	}
+	if e := <-events; e != (event{-1, "hoo", "woo"}) {
+		t.Fatal("Unexpected event", e)
+	}
+
+	select {
+	case e := <-events:
+		t.Fatal("Unexpected event", e)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+)
+
+// OnChange registers a callback that is called after every refresh
+// which modified any config entries.  The callback is provided the
+// new version as well as the old and new values of the modified
+// entries.  Entries that did not exist before are missing from old.
+//
+// Callbacks are called on a separate goroutine, one at a time and in
+// the order of the changes.  A slow callback delays the rest.
+//
+// The returned function can be used to unregister the callback.
+func (c *Cache) OnChange(fn func(version int, old, new map[string]string)) (cancel func()) {
+	return c.watchers.add(fn)
+}
+
+// Watch registers a callback for changes to a specific key.  The old
+// value is empty if the key did not exist before.
+//
+// See OnChange for the ordering guarantees.
+func (c *Cache) Watch(key string, fn func(old, new string)) (cancel func()) {
+	return c.OnChange(func(version int, old, new map[string]string) {
+		if v, ok := new[key]; ok {
+			fn(old[key], v)
+		}
+	})
+}
+
+// change is a single notification
+type change struct {
+	version  int
+	old, new map[string]string
+}
+
+// watchers delivers changes to listeners on a separate goroutine
+type watchers struct {
+	sync.Mutex
+	listeners map[int]func(version int, old, new map[string]string)
+	nextID    int
+	pending   []change
+	cond      *sync.Cond
+	closed    bool
+	wg        sync.WaitGroup
+}
+
+func (w *watchers) add(fn func(version int, old, new map[string]string)) func() {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.listeners == nil {
+		w.listeners = map[int]func(version int, old, new map[string]string){}
+		w.cond = sync.NewCond(&w.Mutex)
+		w.wg.Add(1)
+		go w.run()
+	}
+
+	id := w.nextID
+	w.nextID++
+	w.listeners[id] = fn
+	return func() {
+		w.Lock()
+		defer w.Unlock()
+		delete(w.listeners, id)
+	}
+}
+
+func (w *watchers) notify(ch change) {
+	w.Lock()
+	defer w.Unlock()
+	if w.closed || len(w.listeners) == 0 {
+		return
+	}
+	w.pending = append(w.pending, ch)
+	w.cond.Signal()
+}
+
+func (w *watchers) close() {
+	w.Lock()
+	if w.closed || w.listeners == nil {
+		w.closed = true
+		w.Unlock()
+		return
+	}
+	w.closed = true
+	w.cond.Signal()
+	w.Unlock()
+	w.wg.Wait()
+}
+
+func (w *watchers) run() {
+	defer w.wg.Done()
+
+	w.Lock()
+	defer w.Unlock()
+	for {
+		for len(w.pending) == 0 && !w.closed {
+			w.cond.Wait()
+		}
+		if w.closed {
+			return
+		}
+
+		ch := w.pending[0]
+		w.pending = w.pending[1:]
+		ids := make([]int, 0, len(w.listeners))
+		for id := range w.listeners {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+
+		for _, id := range ids {
+			fn, ok := w.listeners[id]
+			if !ok {
+				continue
+			}
+			w.Unlock()
+			fn(ch.version, ch.old, ch.new)
+			w.Lock()
+		}
+	}
+}
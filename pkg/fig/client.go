@@ -7,7 +7,7 @@
 //      ...
 //      var cfg := fig.Config(url, key, secret, time.Second)
 //      ...
-//      val, err := cfg.Get("my.entry", map[string]string{"user": 42})
+//      val, err := cfg.Get("my.entry", map[string]interface{}{"user": 42})
 //
 // The arg provided to Get is typically a map or a struct whose
 // fields can be accessed by the setting definition to provide the
@@ -16,6 +16,7 @@
 package fig
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -100,6 +101,40 @@ func (c *Client) History(key, epoch string) (string, []string) {
 	return got.Epoch, got.History
 }
 
+// Expose posts exposure events to the server which aggregates the
+// counts per key and result.
+//
+// Like the other methods, this panics on failure. Use Batch to
+// send exposures in the background.
+func (c *Client) Expose(events ...Exposure) {
+	body, err := json.Marshal(events)
+	check(err)
+
+	u := mustParse(c.URL)
+	u = u.ResolveReference(mustParse("exposures"))
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader(body))
+	check(err)
+	req.Header.Set("Content-Type", "application/json")
+	r, err := c.Client.Do(c.AddAuthInfo(req))
+	checkResponse(r, err, nil)
+}
+
+// Exposures fetches the aggregated exposure counts for a key.
+//
+// The counts are indexed by the JSON encoding of the result.
+func (c *Client) Exposures(key string) map[string]int {
+	u := mustParse(c.URL)
+	u = u.ResolveReference(mustParse("exposures/" + url.PathEscape(key)))
+	var got struct {
+		Counts map[string]int
+	}
+	req, err := http.NewRequest("GET", u.String(), nil)
+	check(err)
+	r, err := c.Client.Do(c.AddAuthInfo(req))
+	checkResponse(r, err, &got)
+	return got.Counts
+}
+
 func mustParse(s string) *url.URL {
 	u, err := url.Parse(s)
 	check(err)
@@ -3,12 +3,16 @@ package fig
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/rameshvk/fig/pkg/cache"
 	"github.com/rameshvk/fig/pkg/fire"
 	"github.com/rameshvk/fig/pkg/parse"
+	"github.com/rameshvk/fig/pkg/schema"
 )
 
 // Config creates a new config getter which can be used to evaluate
@@ -19,26 +23,91 @@ func Config(url, key, secret string, cacheFor time.Duration) Getter {
 
 // ConfigWithClient returns a getter than can be used to efficiently
 // access configuration entries.
+//
+// Config entries are parsed only once per change: the parsed form
+// is reused by all calls to Get until the entry is modified.
 func ConfigWithClient(c *Client, cacheFor time.Duration) Getter {
-	s := cache.New(c, cacheFor, nil)
-	return getter(func(key string, arg interface{}) (interface{}, error) {
-		_, cfg := s.GetSince(-1)
-		ctx := context.Background()
-		if entry, ok := cfg[key]; ok {
-			parsed, errs := parse.String(entry)
-			if len(errs) > 0 {
-				return nil, errs[0]
-			}
-			pair := [2]fire.Value{fire.String("it"), fire.FromNative(ctx, arg)}
-			scope := fire.Scope(ctx, fire.Globals(), pair)
-			result := fire.ToNative(ctx, fire.Eval(ctx, parsed, scope))
-			if err, ok := result.(error); ok {
-				return nil, err
-			}
-			return result, nil
-		}
-		return nil, ErrConfigNotFound
-	})
+	return ConfigWithStore(cache.New(c, cacheFor, nil))
+}
+
+// ConfigWithStore returns a getter that uses the provided store
+// which is expected to do its own caching.
+//
+// This allows the cache to be refreshed in the background:
+//
+//      store := cache.NewBackground(fig.New(url).WithKey(key, secret), time.Second)
+//      defer store.Close()
+//      cfg := fig.ConfigWithStore(store)
+//
+// The same store can also be used to watch for changes to specific
+// entries (see cache.Cache.Watch).
+//
+// If the store fails, Get returns the error instead of panicking.
+func ConfigWithStore(s cache.Store) Getter {
+	return ConfigWithOptions(s, Options{})
+}
+
+// Options holds the optional settings for ConfigWithOptions
+type Options struct {
+	// Globals are extra global variables available to all config
+	// entries.  These are layered over the standard globals and so
+	// can override them.  The `config` global, used to reference
+	// other entries, cannot be overridden.
+	//
+	// The values are converted using fire.FromNative, so fire
+	// values (such as functions created with fire.Function or
+	// fire.NativeFunction) can be used as well as plain constants.
+	Globals map[string]interface{}
+
+	// Exposures, if set, receives an exposure event for every
+	// successful call to Get.
+	Exposures ExposureSink
+
+	// Limits restricts the work done by each call to Get so that
+	// a bad config entry cannot hang the caller.  If not set,
+	// fire.DefaultLimits is used.
+	Limits fire.Limits
+
+	// Clock, if set, provides the current time for `time.now()`
+	// and `time.ramp`.  This allows tests to pin the time.
+	Clock func() time.Time
+}
+
+// ConfigWithOptions is like ConfigWithStore but allows customizing
+// the evaluation of config entries.  For example, a service can
+// expose its own functions:
+//
+//      country := fire.Function(code, func(ctx context.Context, args ...fire.Value) fire.Value {
+//              ...
+//      })
+//      geo := fire.Object(map[fire.Value]fire.Value{fire.String("country"): country})
+//      cfg := fig.ConfigWithOptions(store, fig.Options{
+//              Globals: map[string]interface{}{"geo": geo},
+//      })
+//
+// Config entries can then use `geo.country(it.ip)`.
+func ConfigWithOptions(s cache.Store, opts Options) Getter {
+	ctx := context.Background()
+	pairs := [][2]fire.Value{}
+	for k, v := range opts.Globals {
+		pairs = append(pairs, [2]fire.Value{fire.String(k), fire.FromNative(ctx, v)})
+	}
+
+	limits := opts.Limits
+	if limits == (fire.Limits{}) {
+		limits = fire.DefaultLimits
+	}
+
+	return &config{
+		store:     s,
+		exposures: opts.Exposures,
+		globals:   fire.Scope(ctx, fire.Globals(), pairs...),
+		limits:    limits,
+		clock:     opts.Clock,
+		version:   -1,
+		compiled:  map[string]*compiled{},
+		schemas:   map[string]*compiledSchema{},
+	}
 }
 
 // Getter allows fetching configuration entries
@@ -49,8 +118,252 @@ type Getter interface {
 // ErrConfigNotFound is returned by GetConfig if config is not found
 var ErrConfigNotFound = errors.New("config not found")
 
-type getter func(key string, arg interface{}) (interface{}, error)
+type config struct {
+	store     cache.Store
+	exposures ExposureSink
+	globals   fire.Value
+	limits    fire.Limits
+	clock     func() time.Time
+
+	sync.Mutex
+	version  int
+	compiled map[string]*compiled
+	schemas  map[string]*compiledSchema
+}
+
+// compiled is the parsed form of a single config entry
+type compiled struct {
+	source string
+	parsed interface{}
+	err    error
+}
+
+// compiledSchema is the parsed form of the schema for a key
+type compiledSchema struct {
+	source string
+	schema *schema.Schema
+	err    error
+}
+
+// Explainer is implemented by getters which can explain how the
+// result of Get was arrived at.
+//
+// The steps are the sub-expressions evaluated (see fire.WithTrace).
+type Explainer interface {
+	Explain(key string, arg interface{}) (result interface{}, steps []fire.Step, err error)
+}
+
+func (c *config) Get(key string, arg interface{}) (interface{}, error) {
+	return c.eval(context.Background(), newEvaluation(c), key, arg)
+}
+
+func (c *config) Explain(key string, arg interface{}) (interface{}, []fire.Step, error) {
+	ctx, trace := fire.WithTrace(context.Background())
+	result, err := c.eval(ctx, newEvaluation(c), key, arg)
+	return result, trace.Steps, err
+}
+
+func (c *config) eval(ctx context.Context, e *evaluation, key string, arg interface{}) (interface{}, error) {
+	ctx = c.context(ctx)
+	value, version, err := e.value(ctx, key, fire.FromNative(ctx, arg))
+	if err != nil {
+		return nil, err
+	}
+	result := fire.ToNative(ctx, value)
+	if err, ok := result.(error); ok {
+		return nil, err
+	}
+	if c.exposures != nil {
+		c.exposures.Expose(Exposure{key, version, Fingerprint(arg), result, time.Now()})
+	}
+	return result, nil
+}
+
+// context applies the limits and the clock of the config
+func (c *config) context(ctx context.Context) context.Context {
+	ctx = fire.WithLimits(ctx, c.limits)
+	if c.clock != nil {
+		ctx = fire.WithClock(ctx, c.clock)
+	}
+	return ctx
+}
+
+// Decoder is implemented by getters which can decode the result of
+// Get into Go values:
+//
+//      var rollout struct{ Percent float64 }
+//      err := cfg.(fig.Decoder).Decode("rollout", user, &rollout)
+//
+// If the key has a schema (see package schema), the result is
+// verified against it first.  The result is then decoded using
+// encoding/json.
+type Decoder interface {
+	Decode(key string, arg interface{}, v interface{}) error
+}
+
+func (c *config) Decode(key string, arg interface{}, v interface{}) error {
+	// the schema must come from the same version of the config
+	e := newEvaluation(c)
+	result, err := c.eval(context.Background(), e, key, arg)
+	if err != nil {
+		return err
+	}
+	_, cfg, err := e.snapshot()
+	if err != nil {
+		return err
+	}
+	sch, err := c.schema(cfg, key)
+	if err != nil {
+		return err
+	}
+	if sch != nil {
+		if err := sch.Validate(result); err != nil {
+			return fmt.Errorf("%s: result does not match schema: %v", key, err)
+		}
+	}
+
+	data, err := json.Marshal(schema.Normalize(result))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// schema returns the parsed schema for the key or nil if the key
+// does not have one
+func (c *config) schema(cfg map[string]string, key string) (*schema.Schema, error) {
+	source, ok := cfg[schema.Key(key)]
+	if !ok {
+		return nil, nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	if entry, ok := c.schemas[key]; ok && entry.source == source {
+		return entry.schema, entry.err
+	}
+	sch, err := schema.Parse(source)
+	if err != nil {
+		err = fmt.Errorf("%s: invalid schema: %v", key, err)
+	}
+	c.schemas[key] = &compiledSchema{source, sch, err}
+	return sch, err
+}
+
+// Subscriber is implemented by getters which can notify callers of
+// changes to the value of a config entry.
+//
+// Values change when the streams the entry depends on change (see
+// fire.Stream) and when the entry itself or any entry it references
+// via `config.get` is modified, if the store reports changes (as
+// cache.Cache does).
+//
+// Each subscription evaluates the entry again when a stream read by
+// its last evaluation is replaced.  This happens synchronously in the
+// goroutine calling fire.Replace, so replacing a stream which many
+// subscriptions depend on costs as much as that many calls to Get.
+type Subscriber interface {
+	// Subscribe calls fn with the current result of Get and then
+	// again every time the result changes.  The returned function
+	// stops the notifications.
+	Subscribe(key string, arg interface{}, fn func(result interface{}, err error)) (cancel func())
+}
+
+// notifier is implemented by stores which report changes, such as
+// cache.Cache
+type notifier interface {
+	OnChange(fn func(version int, old, new map[string]string)) (cancel func())
+}
+
+func (c *config) Subscribe(key string, arg interface{}, fn func(result interface{}, err error)) func() {
+	var err error
+	var mu sync.Mutex
+	var keys map[string]bool
+	w := fire.Watch(func(ctx context.Context) fire.Value {
+		var v fire.Value
+		ctx = c.context(ctx)
+		e := newEvaluation(c)
+		v, _, err = e.value(ctx, key, fire.FromNative(ctx, arg))
+		mu.Lock()
+		keys = e.keys
+		mu.Unlock()
+		if err != nil {
+			return fire.Error(err.Error())
+		}
+		return fire.Snapshot(ctx, v)
+	}, func(v fire.Value) {
+		if err != nil {
+			fn(nil, err)
+			return
+		}
+		result := fire.ToNative(context.Background(), v)
+		if err, ok := result.(error); ok {
+			fn(nil, err)
+			return
+		}
+		fn(result, nil)
+	})
+
+	n, ok := c.store.(notifier)
+	if !ok {
+		return w.Cancel
+	}
+	// the result also changes when the entries referenced via
+	// `config.get` change
+	stop := n.OnChange(func(version int, old, new map[string]string) {
+		mu.Lock()
+		changed := false
+		for k := range keys {
+			_, inOld := old[k]
+			_, inNew := new[k]
+			changed = changed || inOld || inNew
+		}
+		mu.Unlock()
+		if changed {
+			w.Check()
+		}
+	})
+	return func() {
+		stop()
+		w.Cancel()
+	}
+}
+
+// entry returns the compiled form of key in the provided version of
+// the config.
+//
+// Compiled entries are dropped only when the store reports a new
+// version and the source of that entry has actually changed.
+func (c *config) entry(version int, cfg map[string]string, key string) (*compiled, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if version != c.version {
+		for k, entry := range c.compiled {
+			if source, ok := cfg[k]; !ok || source != entry.source {
+				delete(c.compiled, k)
+			}
+		}
+		c.version = version
+	}
+
+	source, ok := cfg[key]
+	if !ok {
+		return nil, ErrConfigNotFound
+	}
+
+	// an evaluation of an older version may still be running
+	if entry, ok := c.compiled[key]; ok && entry.source == source {
+		return entry, nil
+	}
 
-func (g getter) Get(key string, arg interface{}) (interface{}, error) {
-	return g(key, arg)
+	entry := &compiled{source: source}
+	parsed, errs := parse.String(source)
+	if len(errs) > 0 {
+		entry.err = errs[0]
+	} else {
+		entry.parsed, entry.err = fire.Expand(c.context(context.Background()), parsed, c.globals)
+	}
+	c.compiled[key] = entry
+	return entry, nil
 }
@@ -1,13 +1,20 @@
 package fig_test
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis"
+	"github.com/rameshvk/fig/pkg/cache"
 	"github.com/rameshvk/fig/pkg/fig"
+	"github.com/rameshvk/fig/pkg/fig/figtest"
+	"github.com/rameshvk/fig/pkg/fire"
 	"github.com/rameshvk/fig/pkg/server"
 )
 
@@ -58,6 +65,68 @@ func TestConfig(t *testing.T) {
 	}
 }
 
+func TestConfigChanges(t *testing.T) {
+	store, url, key, secret, cleanup := getStoreAndInfo()
+	defer cleanup()
+
+	cfg := fig.Config(url, key, secret, time.Millisecond)
+	store.Set("boo", `it + 1`)
+	store.Set("woo", `"woo"`)
+	if v, err := cfg.Get("boo", 1); v != 2.0 || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+
+	store.Set("boo", `it + 2`)
+	time.Sleep(2 * time.Millisecond)
+	if v, err := cfg.Get("boo", 1); v != 3.0 || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+	if v, err := cfg.Get("woo", nil); v != "woo" || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+
+	store.Set("boo", `it +`)
+	time.Sleep(2 * time.Millisecond)
+	if v, err := cfg.Get("boo", 1); err == nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+
+	if v, err := cfg.Get("missing", 1); err != fig.ErrConfigNotFound {
+		t.Fatal("Unexpected config", v, err)
+	}
+}
+
+func BenchmarkGetConstant(b *testing.B) {
+	benchmarkGet(b, `"hoo"`, nil)
+}
+
+func BenchmarkGetRule(b *testing.B) {
+	arg := struct {
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}{"boo@example.com", 42}
+	benchmarkGet(b, `if(it.email == "boo@example.com" & it.age > 18, "hoo", "woo")`, arg)
+}
+
+func benchmarkGet(b *testing.B, setting string, arg interface{}) {
+	store, url, key, secret, cleanup := getStoreAndInfo()
+	defer cleanup()
+
+	cfg := fig.Config(url, key, secret, time.Hour)
+	store.Set("my.setting", setting)
+	if _, err := cfg.Get("my.setting", arg); err != nil {
+		b.Fatal("Unexpected error", err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for kk := 0; kk < b.N; kk++ {
+		if _, err := cfg.Get("my.setting", arg); err != nil {
+			b.Fatal("Unexpected error", err)
+		}
+	}
+}
+
 func getStoreAndInfo() (server.Store, string, string, string, func()) {
 	s, err := miniredis.Run()
 	if err != nil {
@@ -82,3 +151,249 @@ func getStoreAndInfo() (server.Store, string, string, string, func()) {
 
 	return store, ts.URL, "mykey", "mysecret", cleanup
 }
+
+func TestConfigWithStore(t *testing.T) {
+	store, url, key, secret, cleanup := getStoreAndInfo()
+
+	store.Set("boo", `"hoo"`)
+	s := cache.NewBackground(fig.New(url).WithKey(key, secret), time.Millisecond)
+	defer s.Close()
+
+	cfg := fig.ConfigWithStore(s)
+	if v, err := cfg.Get("boo", nil); v != "hoo" || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+
+	// continue serving after the server is down
+	cleanup()
+	for s.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if v, err := cfg.Get("boo", nil); v != "hoo" || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+
+	// errors are returned, not panics
+	cfg = fig.ConfigWithStore(cache.New(fig.New(url).WithKey(key, secret), time.Millisecond, nil))
+	if v, err := cfg.Get("boo", nil); err == nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+}
+
+func TestConfigWithOptions(t *testing.T) {
+	store, url, key, secret, cleanup := getStoreAndInfo()
+	defer cleanup()
+
+	code := func(ctx context.Context) string { return "geo.country" }
+	country := fire.Function(code, func(ctx context.Context, args ...fire.Value) fire.Value {
+		if ip, ok := args[0].String(ctx); ok && strings.HasPrefix(ip, "10.") {
+			return fire.String("US")
+		}
+		return fire.String("unknown")
+	})
+	geo := fire.Object(map[fire.Value]fire.Value{fire.String("country"): country})
+
+	s := cache.New(fig.New(url).WithKey(key, secret), time.Millisecond, nil)
+	cfg := fig.ConfigWithOptions(s, fig.Options{
+		Globals: map[string]interface{}{
+			"geo":   geo,
+			"limit": 10,
+			"error": "overridden",
+		},
+	})
+
+	store.Set("boo", `if(geo.country(it.ip) == "US", limit, 0)`)
+	if v, err := cfg.Get("boo", map[string]string{"ip": "10.0.0.1"}); v != 10.0 || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+	if v, err := cfg.Get("boo", map[string]string{"ip": "192.168.0.1"}); v != 0.0 || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+
+	store.Set("woo", `error`)
+	time.Sleep(2 * time.Millisecond)
+	if v, err := cfg.Get("woo", nil); v != "overridden" || err != nil {
+		t.Fatal("Unexpected config", v, err)
+	}
+}
+
+func TestConfigLimits(t *testing.T) {
+	cfg := figtest.NewWithOptions(map[string]string{
+		"recursive": `if(true, f(1), 2, where(f = {f(it)}))`,
+		"sum":       `it + 1 + 2 + 3`,
+	}, fig.Options{Limits: fire.Limits{Steps: 10}})
+
+	if _, err := cfg.Get("recursive", nil); err == nil || !strings.HasPrefix(err.Error(), "step limit exceeded") {
+		t.Fatal("Unexpected error", err)
+	}
+
+	// the budget is per call
+	for i := 0; i < 3; i++ {
+		if v, err := cfg.Get("sum", 1.0); v != 7.0 || err != nil {
+			t.Fatal("Unexpected result", v, err)
+		}
+	}
+
+	cfg = figtest.New(map[string]string{"recursive": `if(true, f(1), 2, where(f = {f(it)}))`})
+	if _, err := cfg.Get("recursive", nil); err == nil || !strings.HasPrefix(err.Error(), "depth limit exceeded") {
+		t.Fatal("Unexpected error", err)
+	}
+}
+
+func TestConfigClock(t *testing.T) {
+	pinned := time.Date(2019, 10, 8, 0, 0, 0, 0, time.UTC)
+	cfg := figtest.NewWithOptions(map[string]string{
+		"rollout": `time.ramp(start = "2019-10-07", end = "2019-10-09")`,
+	}, fig.Options{Clock: func() time.Time { return pinned }})
+
+	if v, err := cfg.Get("rollout", nil); v != 0.5 || err != nil {
+		t.Fatal("Unexpected result", v, err)
+	}
+}
+
+func TestConfigMacros(t *testing.T) {
+	cfg := figtest.New(map[string]string{
+		"doc": `list(note("the default"), it + 1, where(note = macro(where())))`,
+		"bad": `list(f, where(f = macro({it})))`,
+	})
+
+	if v, err := cfg.Get("doc", 1.0); !reflect.DeepEqual(v, []interface{}{2.0}) || err != nil {
+		t.Fatal("Unexpected result", v, err)
+	}
+	if _, err := cfg.Get("bad", nil); err == nil || !strings.HasPrefix(err.Error(), "macro must be called: f") {
+		t.Fatal("Unexpected error", err)
+	}
+}
+
+func TestConfigDecode(t *testing.T) {
+	cfg := figtest.New(map[string]string{
+		"rollout":        `object(percent = if(it.beta, 50, 10), name = "boo")`,
+		"schema:rollout": `{"properties": {"percent": {"type": "number", "maximum": 100}}}`,
+		"plain":          `list(1, 2)`,
+		"bad":            `1`,
+		"schema:bad":     `{"type": 1}`,
+	})
+
+	var rollout struct {
+		Percent float64
+		Name    string
+	}
+	if err := cfg.Decode("rollout", map[string]interface{}{"beta": true}, &rollout); err != nil || rollout.Percent != 50 || rollout.Name != "boo" {
+		t.Fatal("Unexpected result", rollout, err)
+	}
+
+	var plain []int
+	if err := cfg.Decode("plain", nil, &plain); err != nil || !reflect.DeepEqual(plain, []int{1, 2}) {
+		t.Fatal("Unexpected result", plain, err)
+	}
+
+	cfg.Set("rollout", `object(percent = "10")`)
+	err := cfg.Decode("rollout", nil, &rollout)
+	if err == nil || err.Error() != "rollout: result does not match schema: /percent: expected number, got string" {
+		t.Fatal("Unexpected error", err)
+	}
+
+	var n int
+	err = cfg.Decode("bad", nil, &n)
+	if err == nil || err.Error() != "bad: invalid schema: /type: must be a string or array of strings" {
+		t.Fatal("Unexpected error", err)
+	}
+	if err = cfg.Decode("missing", nil, &n); err != fig.ErrConfigNotFound {
+		t.Fatal("Unexpected error", err)
+	}
+}
+
+// changingStore returns the next version of the config on every fetch
+type changingStore struct {
+	sync.Mutex
+	versions []map[string]string
+	fetches  int
+}
+
+func (s *changingStore) GetSince(version int) (int, map[string]string) {
+	s.Lock()
+	defer s.Unlock()
+	s.fetches++
+	n := s.fetches
+	if n > len(s.versions) {
+		n = len(s.versions)
+	}
+	return n, s.versions[n-1]
+}
+
+func (s *changingStore) Set(key, val string) {}
+
+func (s *changingStore) History(key, epoch string) (string, []string) {
+	return "", nil
+}
+
+func TestConfigDecodeSnapshot(t *testing.T) {
+	store := &changingStore{versions: []map[string]string{
+		{"rollout": `50`, "schema:rollout": `{"maximum": 100}`},
+		{"rollout": `5`, "schema:rollout": `{"maximum": 10}`},
+	}}
+	cfg := fig.ConfigWithStore(store)
+
+	var n float64
+	if err := cfg.(fig.Decoder).Decode("rollout", nil, &n); err != nil || n != 50 {
+		t.Fatal("Unexpected result", n, err)
+	}
+	if err := cfg.(fig.Decoder).Decode("rollout", nil, &n); err != nil || n != 5 {
+		t.Fatal("Unexpected result", n, err)
+	}
+}
+
+func TestConfigSubscribe(t *testing.T) {
+	ctx := context.Background()
+	enabled := fire.Stream(fire.Bool(false))
+	cfg := figtest.NewWithOptions(map[string]string{
+		"feature": `if(enabled, it.percent, 0)`,
+	}, fig.Options{Globals: map[string]interface{}{"enabled": enabled}})
+
+	results := []interface{}{}
+	cancel := cfg.Subscribe("feature", map[string]interface{}{"percent": 10}, func(v interface{}, err error) {
+		if err != nil {
+			v = err.Error()
+		}
+		results = append(results, v)
+	})
+
+	fire.Replace(ctx, enabled, fire.Bool(true))
+	fire.Replace(ctx, enabled, fire.Bool(true))
+	cfg.Set("feature", `if(enabled, it.percent * 2, 0)`)
+	cfg.Set("feature", `if(enabled, it.percent * 2,`)
+	cancel()
+	fire.Replace(ctx, enabled, fire.Bool(false))
+
+	if len(results) != 4 || results[0] != 0.0 || results[1] != 10.0 || results[2] != 20.0 {
+		t.Fatal("Unexpected results", results)
+	}
+	if s, _ := results[3].(string); s == "" {
+		t.Fatal("Unexpected error", results[3])
+	}
+
+	// changes to referenced entries are also reported
+	refs := figtest.New(map[string]string{
+		"flag":          `config.get("segments.beta")`,
+		"segments.beta": `config.get("segments.all") & it.beta`,
+		"segments.all":  `true`,
+	})
+	results = []interface{}{}
+	cancel = refs.Subscribe("flag", map[string]interface{}{"beta": true}, func(v interface{}, err error) {
+		results = append(results, v)
+	})
+	defer cancel()
+
+	refs.Set("segments.beta", `false`)
+	refs.Set("segments.all", `false`)
+	refs.Set("segments.beta", `config.get("segments.all")`)
+	refs.Set("segments.all", `true`)
+	refs.Set("unrelated", `true`)
+
+	if !reflect.DeepEqual(results, []interface{}{true, false, true}) {
+		t.Fatal("Unexpected results", results)
+	}
+	if v, err := refs.Get("flag", nil); v != true || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+}
@@ -0,0 +1,200 @@
+package fig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/schema"
+)
+
+// Exposure records a single evaluation of a config entry.
+//
+// Exposures are used for experiments: they capture which value of a
+// setting was seen by which user (or whatever the arg represents).
+type Exposure struct {
+	Key     string      `json:"key"`
+	Version int         `json:"version"`
+	Arg     string      `json:"arg"`
+	Result  interface{} `json:"result"`
+	Time    time.Time   `json:"time"`
+}
+
+// ExposureSink receives exposure events.  See Options.Exposures.
+//
+// Sinks are called synchronously from Get, so slow sinks should be
+// wrapped with Batch.
+type ExposureSink interface {
+	Expose(events ...Exposure)
+}
+
+// Fingerprint returns a short stable hash of the arg provided to
+// Get.  This is what is stored in Exposure.Arg.
+//
+// The arg is hashed as JSON (with sorted keys), so equal args have
+// the same fingerprint regardless of where they are stored.
+func Fingerprint(arg interface{}) string {
+	ctx := context.Background()
+	native := schema.Normalize(fire.ToNative(ctx, fire.FromNative(ctx, arg)))
+	data, err := json.Marshal(native)
+	if err != nil {
+		data = []byte(fmt.Sprintf("%v", native))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sample forwards only a fraction of the exposures to the provided
+// sink.
+//
+// Sampling is based on the fingerprint of the arg, so all
+// exposures of a given user are either forwarded or dropped.
+func Sample(sink ExposureSink, rate float64) ExposureSink {
+	return sampled{sink, rate}
+}
+
+type sampled struct {
+	ExposureSink
+	rate float64
+}
+
+func (s sampled) Expose(events ...Exposure) {
+	result := make([]Exposure, 0, len(events))
+	for _, e := range events {
+		sum := sha256.Sum256([]byte(e.Arg))
+		if float64(binary.BigEndian.Uint32(sum[:4]))/(1<<32) < s.rate {
+			result = append(result, e)
+		}
+	}
+	if len(result) > 0 {
+		s.ExposureSink.Expose(result...)
+	}
+}
+
+// Batch buffers exposures and forwards them to the provided sink in
+// batches of the provided size, or every interval, whichever comes
+// first.  The sink is called on a separate goroutine.
+//
+// If the sink falls behind by more than 10 batches, new exposures
+// are dropped.  Panics in the sink are recovered and the batch is
+// dropped, so Client can be used directly as the sink.
+//
+// Close must be called to flush pending exposures and stop the
+// background goroutine.
+func Batch(sink ExposureSink, size int, interval time.Duration) *Batcher {
+	if size < 1 {
+		size = 1
+	}
+	b := &Batcher{
+		sink:  sink,
+		size:  size,
+		flush: make(chan chan struct{}),
+		kick:  make(chan struct{}, 1),
+		done:  make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.run(interval)
+	return b
+}
+
+// Batcher is an ExposureSink that forwards exposures in batches.
+// See Batch.
+type Batcher struct {
+	sink ExposureSink
+	size int
+
+	sync.Mutex
+	pending []Exposure
+
+	flush     chan chan struct{}
+	kick      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Expose buffers the exposures
+func (b *Batcher) Expose(events ...Exposure) {
+	b.Lock()
+	defer b.Unlock()
+
+	if len(b.pending)+len(events) > 10*b.size {
+		return
+	}
+	b.pending = append(b.pending, events...)
+	if len(b.pending) >= b.size {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush forwards all pending exposures and waits for the sink to be
+// done with them.
+func (b *Batcher) Flush() {
+	ack := make(chan struct{})
+	select {
+	case b.flush <- ack:
+		<-ack
+	case <-b.done:
+	}
+}
+
+// Close flushes pending exposures and stops the background goroutine
+func (b *Batcher) Close() error {
+	b.closeOnce.Do(func() { close(b.done) })
+	b.wg.Wait()
+	return nil
+}
+
+func (b *Batcher) run(interval time.Duration) {
+	defer b.wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-b.done:
+			b.forward()
+			return
+		case ack := <-b.flush:
+			b.forward()
+			close(ack)
+		case <-b.kick:
+			b.forward()
+		case <-ticker.C:
+			b.forward()
+		}
+	}
+}
+
+// forward sends all pending exposures in batches
+func (b *Batcher) forward() {
+	b.Lock()
+	pending := b.pending
+	b.pending = nil
+	b.Unlock()
+
+	for len(pending) > 0 {
+		n := b.size
+		if n > len(pending) {
+			n = len(pending)
+		}
+		b.send(pending[:n])
+		pending = pending[n:]
+	}
+}
+
+func (b *Batcher) send(events []Exposure) {
+	defer func() {
+		_ = recover()
+	}()
+	b.sink.Expose(events...)
+}
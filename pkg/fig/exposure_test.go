@@ -0,0 +1,122 @@
+package fig_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rameshvk/fig/pkg/cache"
+	"github.com/rameshvk/fig/pkg/fig"
+	"github.com/rameshvk/fig/pkg/fig/figtest"
+)
+
+func TestExposures(t *testing.T) {
+	store, url, key, secret, cleanup := getStoreAndInfo()
+	defer cleanup()
+
+	store.Set("boo", `if(it.user == "boo", "hoo", "woo")`)
+	sink := &figtest.Sink{}
+	s := cache.New(fig.New(url).WithKey(key, secret), time.Second, nil)
+	cfg := fig.ConfigWithOptions(s, fig.Options{Exposures: sink})
+
+	arg := map[string]string{"user": "boo"}
+	start := time.Now()
+	if v, err := cfg.Get("boo", arg); v != "hoo" || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+	if v, err := cfg.Get("missing", arg); err == nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	exposures := sink.Exposures()
+	if len(exposures) != 1 || exposures[0].Time.Before(start) {
+		t.Fatal("Unexpected exposures", exposures)
+	}
+	exposures[0].Time = time.Time{}
+	expected := fig.Exposure{Key: "boo", Version: 1, Arg: fig.Fingerprint(arg), Result: "hoo"}
+	if !reflect.DeepEqual(exposures[0], expected) {
+		t.Fatal("Unexpected exposure", exposures[0])
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	type A struct{ B string }
+	type U struct {
+		Name string
+		A    *A
+	}
+
+	x, y := U{"a", &A{"b"}}, U{"a", &A{"b"}}
+	if fig.Fingerprint(x) != fig.Fingerprint(y) {
+		t.Error("Equal values have different fingerprints")
+	}
+	if fig.Fingerprint(x) == fig.Fingerprint(U{"a", &A{"c"}}) {
+		t.Error("Different values have the same fingerprint")
+	}
+
+	m1 := map[string]interface{}{"x": 1, "y": []int{2}, "z": &A{"b"}}
+	m2 := map[string]interface{}{"z": &A{"b"}, "y": []int{2}, "x": 1}
+	if fig.Fingerprint(m1) != fig.Fingerprint(m2) {
+		t.Error("Equal maps have different fingerprints")
+	}
+}
+
+func TestExposureSampling(t *testing.T) {
+	events := []fig.Exposure{}
+	for _, user := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		events = append(events, fig.Exposure{Key: "boo", Arg: fig.Fingerprint(user)})
+	}
+
+	all, none, some := &figtest.Sink{}, &figtest.Sink{}, &figtest.Sink{}
+	fig.Sample(all, 1).Expose(events...)
+	fig.Sample(none, 0).Expose(events...)
+	fig.Sample(some, 0.5).Expose(events...)
+	fig.Sample(some, 0.5).Expose(events...)
+
+	if len(all.Exposures()) != len(events) || len(none.Exposures()) != 0 {
+		t.Fatal("Unexpected sampling", all.Exposures(), none.Exposures())
+	}
+
+	// sampling must be sticky
+	got := some.Exposures()
+	n := len(got) / 2
+	if n == 0 || n == len(events) || !reflect.DeepEqual(got[:n], got[n:]) {
+		t.Fatal("Unexpected sampling", got)
+	}
+}
+
+func TestExposureBatching(t *testing.T) {
+	_, url, key, secret, cleanup := getStoreAndInfo()
+	defer cleanup()
+
+	c := fig.New(url).WithKey(key, secret)
+	b := fig.Batch(c, 2, time.Hour)
+	b.Expose(
+		fig.Exposure{Key: "boo", Result: "hoo"},
+		fig.Exposure{Key: "boo", Result: "hoo"},
+		fig.Exposure{Key: "boo", Result: 5.0},
+	)
+	b.Flush()
+	expected := map[string]int{`"hoo"`: 2, `5`: 1}
+	if counts := c.Exposures("boo"); !reflect.DeepEqual(counts, expected) {
+		t.Fatal("Unexpected counts", counts)
+	}
+
+	b.Expose(fig.Exposure{Key: "boo", Result: true})
+	if err := b.Close(); err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	expected["true"] = 1
+	if counts := c.Exposures("boo"); !reflect.DeepEqual(counts, expected) {
+		t.Fatal("Unexpected counts", counts)
+	}
+
+	// failures are dropped
+	b = fig.Batch(fig.New(url), 1, time.Millisecond)
+	b.Expose(fig.Exposure{Key: "boo", Result: "hoo"})
+	b.Flush()
+	b.Close()
+	if counts := c.Exposures("boo"); !reflect.DeepEqual(counts, expected) {
+		t.Fatal("Unexpected counts", counts)
+	}
+}
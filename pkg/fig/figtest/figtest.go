@@ -0,0 +1,199 @@
+// Package figtest implements an in-memory fig.Getter for tests
+//
+// Code that fetches config via fig.Getter can be tested with the
+// real config definitions without any server:
+//
+//      cfg := figtest.New(map[string]string{
+//              "my.setting": `if(it.user == "boo", "hoo", "woo")`,
+//      })
+//      defer cfg.Override("other.setting", 42)()
+//      ... run code which calls cfg.Get(..) ...
+//      calls := cfg.Calls()
+package figtest
+
+import (
+	"sync"
+
+	"github.com/rameshvk/fig/pkg/fig"
+	"github.com/rameshvk/fig/pkg/fire"
+)
+
+// New creates a getter which evaluates the provided config entries.
+//
+// The entries are fig expressions just as they would be stored on
+// the server.
+func New(entries map[string]string) *Getter {
+	return NewWithOptions(entries, fig.Options{})
+}
+
+// NewWithOptions is like New but allows custom globals. See
+// fig.ConfigWithOptions.
+func NewWithOptions(entries map[string]string, opts fig.Options) *Getter {
+	s := &store{ver: -1, entries: map[string][]string{}}
+	for k, v := range entries {
+		s.Set(k, v)
+	}
+	return &Getter{
+		store:     s,
+		getter:    fig.ConfigWithOptions(s, opts),
+		overrides: map[string]*override{},
+	}
+}
+
+// Getter is an in-memory fig.Getter that records all calls to Get.
+//
+// It is safe for concurrent use.
+type Getter struct {
+	store  *store
+	getter fig.Getter
+
+	sync.Mutex
+	overrides map[string]*override
+	calls     []Call
+}
+
+// Call is a single recorded call to Get
+type Call struct {
+	Key    string
+	Arg    interface{}
+	Result interface{}
+	Err    error
+}
+
+type override struct {
+	value interface{}
+}
+
+// Get evaluates the config entry for key, unless it is overridden.
+func (g *Getter) Get(key string, arg interface{}) (interface{}, error) {
+	g.Lock()
+	o, ok := g.overrides[key]
+	g.Unlock()
+
+	var result interface{}
+	var err error
+	switch {
+	case !ok:
+		result, err = g.getter.Get(key, arg)
+	case isError(o.value):
+		err = o.value.(error)
+	default:
+		result = o.value
+	}
+
+	g.Lock()
+	defer g.Unlock()
+	g.calls = append(g.calls, Call{key, arg, result, err})
+	return result, err
+}
+
+// Explain evaluates the config entry for key and returns the steps
+// taken.  Overrides are ignored.  See fig.Explainer.
+func (g *Getter) Explain(key string, arg interface{}) (interface{}, []fire.Step, error) {
+	return g.getter.(fig.Explainer).Explain(key, arg)
+}
+
+// Subscribe notifies fn of changes to the value of the config
+// entry, including those caused by Set.  Overrides are ignored.  See
+// fig.Subscriber.
+func (g *Getter) Subscribe(key string, arg interface{}, fn func(result interface{}, err error)) (cancel func()) {
+	return g.getter.(fig.Subscriber).Subscribe(key, arg, fn)
+}
+
+// Decode decodes the result of the config entry into v, checking it
+// against the schema for key, if any.  Overrides are ignored.  See
+// fig.Decoder.
+func (g *Getter) Decode(key string, arg interface{}, v interface{}) error {
+	return g.getter.(fig.Decoder).Decode(key, arg, v)
+}
+
+// Set updates the fig expression for the provided key
+func (g *Getter) Set(key, source string) {
+	g.store.Set(key, source)
+}
+
+// Override pins the result of key to the provided value, whatever
+// the arg.  If the value is an error, Get returns that error.
+//
+// Overrides can be nested.  The returned function restores the
+// previous state and is meant to be deferred:
+//
+//      defer cfg.Override("my.setting", true)()
+func (g *Getter) Override(key string, value interface{}) (restore func()) {
+	g.Lock()
+	defer g.Unlock()
+
+	previous, existed := g.overrides[key]
+	current := &override{value}
+	g.overrides[key] = current
+	return func() {
+		g.Lock()
+		defer g.Unlock()
+		if g.overrides[key] != current {
+			return
+		}
+		if existed {
+			g.overrides[key] = previous
+		} else {
+			delete(g.overrides, key)
+		}
+	}
+}
+
+// Calls returns all the calls to Get so far
+func (g *Getter) Calls() []Call {
+	g.Lock()
+	defer g.Unlock()
+	return append([]Call(nil), g.calls...)
+}
+
+// Keys returns the keys passed to Get so far, in order and without
+// duplicates.
+func (g *Getter) Keys() []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, call := range g.Calls() {
+		if !seen[call.Key] {
+			seen[call.Key] = true
+			result = append(result, call.Key)
+		}
+	}
+	return result
+}
+
+// Reset clears the recorded calls
+func (g *Getter) Reset() {
+	g.Lock()
+	defer g.Unlock()
+	g.calls = nil
+}
+
+func isError(v interface{}) bool {
+	_, ok := v.(error)
+	return ok
+}
+
+// Sink is an in-memory fig.ExposureSink
+//
+//      sink := &figtest.Sink{}
+//      cfg := fig.ConfigWithOptions(store, fig.Options{Exposures: sink})
+//      ...
+//      exposures := sink.Exposures()
+type Sink struct {
+	sync.Mutex
+	events []fig.Exposure
+}
+
+// Expose records the exposures
+func (s *Sink) Expose(events ...fig.Exposure) {
+	s.Lock()
+	defer s.Unlock()
+	s.events = append(s.events, events...)
+}
+
+// Exposures returns all the exposures so far
+func (s *Sink) Exposures() []fig.Exposure {
+	s.Lock()
+	defer s.Unlock()
+	return append([]fig.Exposure(nil), s.events...)
+}
@@ -0,0 +1,110 @@
+package figtest_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fig"
+	"github.com/rameshvk/fig/pkg/fig/figtest"
+)
+
+func Example() {
+	cfg := figtest.New(map[string]string{
+		"my.setting": `if(it.user == "boo", "hoo", "woo")`,
+	})
+
+	// code under test would take a fig.Getter
+	var getter fig.Getter = cfg
+	v, err := getter.Get("my.setting", map[string]string{"user": "boo"})
+
+	if v != "hoo" || err != nil {
+		panic("unexpected result")
+	}
+}
+
+func TestGetter(t *testing.T) {
+	cfg := figtest.New(map[string]string{
+		"boo": `it.x + 1`,
+		"woo": `"woo"`,
+	})
+
+	if v, err := cfg.Get("boo", map[string]int{"x": 1}); v != 2.0 || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	cfg.Set("boo", `it.x + 2`)
+	if v, err := cfg.Get("boo", map[string]int{"x": 1}); v != 3.0 || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	restore := cfg.Override("boo", "pinned")
+	if v, err := cfg.Get("boo", nil); v != "pinned" || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	failure := errors.New("failed")
+	restoreNested := cfg.Override("boo", failure)
+	if v, err := cfg.Get("boo", nil); err != failure {
+		t.Fatal("Unexpected value", v, err)
+	}
+	restoreNested()
+	if v, err := cfg.Get("boo", nil); v != "pinned" || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+	restore()
+	restore()
+	if v, err := cfg.Get("boo", map[string]int{"x": 0}); v != 2.0 || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	if v, err := cfg.Get("missing", nil); err != fig.ErrConfigNotFound {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	if keys := cfg.Keys(); !reflect.DeepEqual(keys, []string{"boo", "missing"}) {
+		t.Fatal("Unexpected keys", keys)
+	}
+
+	calls := cfg.Calls()
+	if len(calls) != 7 {
+		t.Fatal("Unexpected calls", calls)
+	}
+	expected := figtest.Call{Key: "boo", Arg: map[string]int{"x": 1}, Result: 2.0}
+	if !reflect.DeepEqual(calls[0], expected) {
+		t.Fatal("Unexpected call", calls[0])
+	}
+
+	cfg.Reset()
+	if calls := cfg.Calls(); len(calls) != 0 {
+		t.Fatal("Unexpected calls", calls)
+	}
+}
+
+func TestGetterWithOptions(t *testing.T) {
+	cfg := figtest.NewWithOptions(
+		map[string]string{"boo": `limit * 2`},
+		fig.Options{Globals: map[string]interface{}{"limit": 5}},
+	)
+	if v, err := cfg.Get("boo", nil); v != 10.0 || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	cfg := figtest.New(map[string]string{"boo": `if(it.x > 1, "big", "small")`})
+
+	var explainer fig.Explainer = cfg
+	v, steps, err := explainer.Explain("boo", map[string]int{"x": 5})
+	if v != "big" || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	ops := []string{}
+	for _, step := range steps {
+		ops = append(ops, step.Op)
+	}
+	if !reflect.DeepEqual(ops, []string{"call", "name", ">", ".", "name"}) {
+		t.Fatal("Unexpected steps", steps)
+	}
+}
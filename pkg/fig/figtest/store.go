@@ -0,0 +1,87 @@
+package figtest
+
+import (
+	"sync"
+)
+
+// store is an in-memory cache.Store
+type store struct {
+	sync.Mutex
+	ver       int
+	versions  map[string]int
+	entries   map[string][]string
+	listeners map[int]func(version int, old, new map[string]string)
+	nextID    int
+}
+
+func (s *store) GetSince(version int) (int, map[string]string) {
+	s.Lock()
+	defer s.Unlock()
+
+	result := map[string]string{}
+	for k, history := range s.entries {
+		if s.versions[k] > version {
+			result[k] = history[len(history)-1]
+		}
+	}
+	return s.ver, result
+}
+
+func (s *store) Set(key, val string) {
+	s.Lock()
+	if s.ver < 1 {
+		s.ver = 0
+		s.versions = map[string]int{}
+	}
+	old := map[string]string{}
+	if history := s.entries[key]; len(history) > 0 {
+		old[key] = history[len(history)-1]
+	}
+	s.ver++
+	s.versions[key] = s.ver
+	s.entries[key] = append(s.entries[key], val)
+
+	version := s.ver
+	listeners := make([]func(int, map[string]string, map[string]string), 0, len(s.listeners))
+	for _, fn := range s.listeners {
+		listeners = append(listeners, fn)
+	}
+	s.Unlock()
+
+	for _, fn := range listeners {
+		fn(version, old, map[string]string{key: val})
+	}
+}
+
+// OnChange registers a callback for changes.  Unlike cache.Cache,
+// the callbacks are called synchronously by Set.
+func (s *store) OnChange(fn func(version int, old, new map[string]string)) (cancel func()) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.listeners == nil {
+		s.listeners = map[int]func(int, map[string]string, map[string]string){}
+	}
+	id := s.nextID
+	s.nextID++
+	s.listeners[id] = fn
+	return func() {
+		s.Lock()
+		defer s.Unlock()
+		delete(s.listeners, id)
+	}
+}
+
+// History returns all values of the key, latest first.  There is no
+// pagination, so the epoch is ignored.
+func (s *store) History(key, epoch string) (string, []string) {
+	s.Lock()
+	defer s.Unlock()
+
+	history := s.entries[key]
+	result := make([]string, len(history))
+	for kk, v := range history {
+		result[len(history)-1-kk] = v
+	}
+	return "", result
+}
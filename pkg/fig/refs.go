@@ -0,0 +1,165 @@
+package fig
+
+import (
+	"context"
+	"strings"
+
+	"github.com/rameshvk/fig/pkg/cache"
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/match"
+)
+
+// evaluation is the state of a single call to Get.  It is shared by
+// all the config entries referenced via `config.get`:
+//
+//      config.get("segments.beta", it) & it.country == "US"
+//
+// Each entry is evaluated at most once per arg and references which
+// lead back to an entry being evaluated fail.  All the entries are
+// read from the same version of the config.
+type evaluation struct {
+	c     *config
+	memo  map[string]fire.Value
+	stack []string
+
+	// keys are all the entries used, including missing ones
+	keys map[string]bool
+
+	fetched bool
+	version int
+	cfg     map[string]string
+	err     error
+}
+
+func newEvaluation(c *config) *evaluation {
+	return &evaluation{c: c, memo: map[string]fire.Value{}, keys: map[string]bool{}}
+}
+
+// snapshot fetches the config on first use
+func (e *evaluation) snapshot() (int, map[string]string, error) {
+	if !e.fetched {
+		e.version, e.cfg, e.err = cache.Fetch(e.c.store, -1)
+		e.fetched = true
+	}
+	return e.version, e.cfg, e.err
+}
+
+func (e *evaluation) value(ctx context.Context, key string, arg fire.Value) (fire.Value, int, error) {
+	e.keys[key] = true
+	version, cfg, err := e.snapshot()
+	if err != nil {
+		return nil, version, err
+	}
+	entry, err := e.c.entry(version, cfg, key)
+	if err != nil {
+		return nil, version, err
+	}
+	if entry.err != nil {
+		return nil, version, entry.err
+	}
+
+	e.stack = append(e.stack, key)
+	defer func() { e.stack = e.stack[:len(e.stack)-1] }()
+
+	scope := fire.Scope(
+		ctx,
+		e.c.globals,
+		[2]fire.Value{fire.String("it"), arg},
+		[2]fire.Value{fire.String("config"), e.object()},
+	)
+	return fire.Eval(ctx, entry.parsed, scope), version, nil
+}
+
+func (e *evaluation) object() fire.Value {
+	get := fire.NativeFunction(func(ctx context.Context) string {
+		return "config.get"
+	}, e.get)
+	return fire.Object(map[fire.Value]fire.Value{fire.String("get"): get})
+}
+
+// get implements `config.get(key, arg)`.  If the arg is not
+// provided, the `it` of the caller is used.
+func (e *evaluation) get(ctx context.Context, args []interface{}, scope fire.Value) fire.Value {
+	if len(args) != 1 && len(args) != 2 {
+		return fire.Error("config.get requires key, it")
+	}
+	k := fire.Eval(ctx, args[0], scope)
+	if _, ok := k.Error(ctx); ok {
+		return k
+	}
+	key, ok := k.String(ctx)
+	if !ok {
+		return fire.Error("config.get: key must be a string")
+	}
+	arg := scope.Lookup(ctx, fire.String("it"))
+	if len(args) == 2 {
+		arg = fire.Eval(ctx, args[1], scope)
+	}
+
+	for kk, k := range e.stack {
+		if k == key {
+			cycle := append(append([]string(nil), e.stack[kk:]...), key)
+			return fire.Error("config.get: cycle " + strings.Join(cycle, " -> "))
+		}
+	}
+
+	memoKey := key + "\x00" + arg.Code(ctx)
+	if v, ok := e.memo[memoKey]; ok {
+		return v
+	}
+	v, _, err := e.value(ctx, key, arg)
+	if err == nil {
+		// locations refer to the source of the other entry
+		if verr, ok := v.Error(ctx); ok {
+			err = verr
+			if eval, ok := verr.(*fire.EvalError); ok {
+				err = &fire.EvalError{Message: eval.Message}
+			}
+		}
+	}
+	if err != nil {
+		v = fire.Error("config.get(" + fire.String(key).Code(ctx) + "): " + err.Error())
+	}
+	e.memo[memoKey] = v
+	return v
+}
+
+// Dependencies returns the keys referenced by a parsed config entry
+// using `config.get` with a constant key.  Keys computed at runtime
+// are not included.
+func Dependencies(parsed interface{}) []string {
+	seen := map[string]bool{}
+	result := []string{}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		list, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		var key string
+		err := match.ListFirst(
+			match.StringPrefix("call"),
+			match.ListFirst(
+				[]interface{}{
+					match.StringPrefix("."),
+					[]interface{}{match.StringPrefix("name"), "config"},
+					[]interface{}{match.StringPrefix("string"), "get"},
+				},
+				match.ListFirst(
+					[]interface{}{match.StringPrefix("string"), &key},
+					match.Any(),
+				),
+			),
+		).Match(v)
+		if err == nil && !seen[key] {
+			seen[key] = true
+			result = append(result, key)
+		}
+		for _, elt := range list {
+			walk(elt)
+		}
+	}
+	walk(parsed)
+	return result
+}
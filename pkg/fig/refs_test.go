@@ -0,0 +1,83 @@
+package fig_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fig"
+	"github.com/rameshvk/fig/pkg/fig/figtest"
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestConfigReferences(t *testing.T) {
+	calls := 0
+	count := fire.Function(func(ctx context.Context) string {
+		return "count"
+	}, func(ctx context.Context, args ...fire.Value) fire.Value {
+		calls++
+		return args[0]
+	})
+
+	cfg := figtest.NewWithOptions(map[string]string{
+		"segments.beta": `count(it.tier != "alpha")`,
+		"flag":          `config.get("segments.beta", it) & (it.country == "US")`,
+		"implicit":      `config.get("segments.beta")`,
+		"twice":         `list(config.get("segments.beta"), config.get("segments.beta"), config.get("segments.beta", object(tier = "beta")))`,
+		"a":             `config.get("b")`,
+		"b":             `config.get("a")`,
+		"self":          `config.get("self", it)`,
+		"missing":       `config.get("nope")`,
+		"dynamic":       `config.get(it.key)`,
+		"bad":           `config.get(1)`,
+		"broken":        `config.get("bad")`,
+	}, fig.Options{Globals: map[string]interface{}{"count": count}})
+
+	user := map[string]interface{}{"tier": "beta", "country": "US"}
+	suite := map[string]interface{}{
+		"flag":     true,
+		"implicit": true,
+		"dynamic":  false,
+	}
+	for key, expected := range suite {
+		arg := map[string]interface{}{"tier": "beta", "country": "US", "key": "flag"}
+		if key == "dynamic" {
+			arg["country"] = "CA"
+		}
+		if v, err := cfg.Get(key, arg); v != expected || err != nil {
+			t.Error("Unexpected result", key, v, err)
+		}
+	}
+
+	// entries are evaluated once per arg within a single Get
+	calls = 0
+	v, err := cfg.Get("twice", map[string]interface{}{"tier": "alpha"})
+	if !reflect.DeepEqual(v, []interface{}{false, false, true}) || err != nil || calls != 2 {
+		t.Error("Unexpected result", v, err, calls)
+	}
+
+	errors := map[string]string{
+		"a":       `config.get("b"): config.get: cycle a -> b -> a`,
+		"self":    `config.get: cycle self -> self`,
+		"missing": `config.get("nope"): config not found`,
+		"bad":     `config.get: key must be a string`,
+		"broken":  `config.get("bad"): config.get: key must be a string`,
+	}
+	for key, expected := range errors {
+		if _, err := cfg.Get(key, user); err == nil || !strings.HasPrefix(err.Error(), expected+" (at") {
+			t.Error("Unexpected error", key, err)
+		}
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	parsed, errs := parse.String(`config.get("a") | config.get("b", it) | config.get("a", 5) | config.get(it.key) | other.get("c")`)
+	if len(errs) > 0 {
+		t.Fatal("Unexpected parse error", errs)
+	}
+	if deps := fig.Dependencies(parsed); !reflect.DeepEqual(deps, []string{"a", "b"}) {
+		t.Error("Unexpected dependencies", deps)
+	}
+}
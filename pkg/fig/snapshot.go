@@ -0,0 +1,138 @@
+package fig
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/rameshvk/fig/pkg/cache"
+)
+
+// Snapshot is a point in time copy of all the config entries
+type Snapshot struct {
+	Version int               `json:"version"`
+	Config  map[string]string `json:"config"`
+}
+
+// ReadSnapshot decodes a JSON encoded snapshot, such as the files
+// saved by Persist.
+//
+// This is useful for bundling a known good snapshot with a service.
+func ReadSnapshot(r io.Reader) (*Snapshot, error) {
+	var s Snapshot
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LoadSnapshot reads the snapshot saved at the provided path
+func LoadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadSnapshot(f)
+}
+
+// Save writes the snapshot to the provided path.
+//
+// The file is replaced atomically, so a crash never leaves a
+// partially written snapshot behind.
+func (s *Snapshot) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Persist wraps a store so that all config fetched from it is also
+// saved to a local file.
+//
+// When the store fails, the last saved snapshot is served instead
+// (or the fallback snapshot if it is newer).  This allows services
+// to start up with known config even if the fig server is down:
+//
+//      client := fig.New(url).WithKey(key, secret)
+//      store := fig.Persist(client, "/var/cache/fig.json", bundled)
+//      cfg := fig.ConfigWithStore(cache.New(store, time.Second, nil))
+//
+// The fallback is optional.  Failures to save the file are ignored.
+func Persist(s cache.Store, path string, fallback *Snapshot) cache.Store {
+	p := &persisted{Store: s, path: path, snapshot: Snapshot{Version: -1}}
+	if saved, err := LoadSnapshot(path); err == nil {
+		p.snapshot = *saved
+	}
+	if fallback != nil && fallback.Version > p.snapshot.Version {
+		p.snapshot = *fallback
+	}
+	return p
+}
+
+type persisted struct {
+	cache.Store
+	path string
+
+	sync.Mutex
+	snapshot Snapshot
+}
+
+func (p *persisted) GetSince(version int) (int, map[string]string) {
+	ver, configs, err := cache.Fetch(p.Store, version)
+
+	p.Lock()
+	defer p.Unlock()
+
+	if err != nil {
+		if p.snapshot.Config == nil || version >= p.snapshot.Version {
+			panic(err)
+		}
+		return p.snapshot.Version, p.snapshot.Config
+	}
+
+	var result map[string]string
+	switch {
+	case version < 1:
+		result = map[string]string{}
+	case version == p.snapshot.Version:
+		result = map[string]string{}
+		for k, v := range p.snapshot.Config {
+			result[k] = v
+		}
+	default:
+		// not a continuation of the snapshot
+		return ver, configs
+	}
+
+	if ver == p.snapshot.Version && len(configs) == 0 {
+		return ver, configs
+	}
+
+	for k, v := range configs {
+		result[k] = v
+	}
+	p.snapshot = Snapshot{Version: ver, Config: result}
+	_ = p.snapshot.Save(p.path)
+	return ver, configs
+}
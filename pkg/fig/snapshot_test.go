@@ -0,0 +1,68 @@
+package fig_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rameshvk/fig/pkg/cache"
+	"github.com/rameshvk/fig/pkg/fig"
+)
+
+func TestPersist(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fig")
+	if err != nil {
+		t.Fatal("tempdir", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "snapshot.json")
+
+	store, url, key, secret, cleanup := getStoreAndInfo()
+	store.Set("boo", `"hoo"`)
+	store.Set("woo", `"woo"`)
+
+	s := fig.Persist(fig.New(url).WithKey(key, secret), path, nil)
+	if ver, cfg := s.GetSince(-1); ver != 2 || len(cfg) != 2 {
+		t.Fatal("Unexpected config", ver, cfg)
+	}
+	store.Set("boo", `"hop"`)
+	if ver, cfg := s.GetSince(2); ver != 3 || cfg["boo"] != `"hop"` {
+		t.Fatal("Unexpected config", ver, cfg)
+	}
+
+	saved, err := fig.LoadSnapshot(path)
+	expected := &fig.Snapshot{Version: 3, Config: map[string]string{"boo": `"hop"`, "woo": `"woo"`}}
+	if err != nil || !reflect.DeepEqual(saved, expected) {
+		t.Fatal("Unexpected snapshot", saved, err)
+	}
+
+	// server goes down and the service restarts
+	cleanup()
+	s = fig.Persist(fig.New(url).WithKey(key, secret), path, nil)
+	cfg := fig.ConfigWithStore(cache.New(s, time.Second, nil))
+	if v, err := cfg.Get("boo", nil); v != "hop" || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	// newer bundled snapshots win
+	bundled, err := fig.ReadSnapshot(strings.NewReader(`{"version": 5, "config": {"boo": "\"bundled\""}}`))
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	s = fig.Persist(fig.New(url).WithKey(key, secret), path, bundled)
+	cfg = fig.ConfigWithStore(cache.New(s, time.Second, nil))
+	if v, err := cfg.Get("boo", nil); v != "bundled" || err != nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+
+	// without any snapshot, errors are returned
+	s = fig.Persist(fig.New(url).WithKey(key, secret), filepath.Join(dir, "missing.json"), nil)
+	cfg = fig.ConfigWithStore(cache.New(s, time.Second, nil))
+	if v, err := cfg.Get("boo", nil); err == nil {
+		t.Fatal("Unexpected value", v, err)
+	}
+}
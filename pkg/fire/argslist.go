@@ -46,18 +46,18 @@ func accumulateAssign(result map[Value]interface{}, arg interface{}) Value {
 			&arg,
 		}).Match(arg)
 		if err != nil {
-			return errorValue(err.Error())
+			return newError(err.Error())
 		}
 		names = append(names, name)
 	}
 
 	if len(names) == 0 {
-		return errorValue("missing = in where")
+		return newError("missing = in where")
 	}
 	for kk, name := range names {
 		key := stringValue(name)
 		if _, ok := result[key]; ok {
-			return errorValue("duplicate name: " + name)
+			return newError("duplicate name: " + name)
 		}
 		if kk == 0 {
 			result[key] = arg
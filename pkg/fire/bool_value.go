@@ -23,12 +23,12 @@ func (b boolValue) HashCode() interface{} {
 }
 
 func (b boolValue) Call(ctx context.Context, args ...Value) Value {
-	return errorValue("cannot call a bool")
+	return newError("cannot call a bool")
 }
 
 func (b boolValue) Lookup(ctx context.Context, field Value) Value {
 	// TODO: add string methods
-	return errorValue("cannot lookup a bool")
+	return newError("cannot lookup a bool")
 }
 
 func (b boolValue) Equals(ctx context.Context, other Value) bool {
@@ -0,0 +1,116 @@
+package fire
+
+import (
+	"context"
+	"strings"
+)
+
+// builtinFn is a function whose args can be passed either
+// positionally, `math.pow(2, 10)`, or by name,
+// `math.pow(x = 2, y = 10)`.  Functions without names take any
+// number of positional args.
+//
+// Unlike NativeFunction, builtins can also be called directly (such
+// as by `list.map`) with the args treated as positional.
+type builtinFn struct {
+	code  string
+	names []string
+	fn    func(ctx context.Context, args []Value) Value
+}
+
+func (b builtinFn) Code(ctx context.Context) string {
+	return b.code
+}
+
+func (b builtinFn) HashCode() interface{} {
+	return "()"
+}
+
+func (b builtinFn) NativeCall(ctx context.Context, args []interface{}, scope Value) Value {
+	values, err := b.values(ctx, args, scope)
+	if err != nil {
+		return err
+	}
+	return b.Call(ctx, values...)
+}
+
+// values evaluates the args, ordering named args by b.names
+func (b builtinFn) values(ctx context.Context, args []interface{}, scope Value) ([]Value, Value) {
+	named := 0
+	for _, arg := range args {
+		if assignPattern.Match(arg) == nil {
+			named++
+		}
+	}
+
+	values := make([]Value, len(args))
+	switch {
+	case named == 0:
+		for kk, arg := range args {
+			values[kk] = Eval(ctx, arg, scope)
+		}
+	case named < len(args):
+		return nil, newError(b.code + ": cannot mix named and unnamed args")
+	case b.names == nil:
+		return nil, newError(b.code + ": named args are not supported")
+	default:
+		o, err := evalArgument(ctx, args, scope)
+		if err != nil {
+			return nil, err
+		}
+		if len(o.(obj)) > len(b.names) {
+			return nil, newError(b.code + ": unexpected args")
+		}
+		values = make([]Value, len(b.names))
+		for kk, name := range b.names {
+			v, ok := o.(obj)[stringValue(name)]
+			if !ok {
+				return nil, newError(b.code + ": missing " + name)
+			}
+			values[kk] = v
+		}
+	}
+	return values, nil
+}
+
+// Call calls the builtin.  If any of the args is a stream, the
+// result is a stream (see lift).
+func (b builtinFn) Call(ctx context.Context, args ...Value) Value {
+	if err := b.check(args); err != nil {
+		return err
+	}
+	return lift(func(ctx context.Context, args ...Value) Value {
+		return b.fn(ctx, args)
+	})(ctx, args...)
+}
+
+func (b builtinFn) check(args []Value) Value {
+	if b.names != nil && len(args) != len(b.names) {
+		return newError(b.code + " requires " + strings.Join(b.names, ", "))
+	}
+	return nil
+}
+
+func (b builtinFn) Lookup(ctx context.Context, field Value) Value {
+	return newError("cannot lookup a function")
+}
+
+func (b builtinFn) Equals(ctx context.Context, other Value) bool {
+	return b.Code(ctx) == other.Code(ctx)
+}
+
+func (b builtinFn) Number(ctx context.Context) (float64, bool) {
+	return 0, false
+}
+
+func (b builtinFn) String(ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (b builtinFn) Bool(ctx context.Context) (bool, bool) {
+	return false, false
+}
+
+func (b builtinFn) Error(ctx context.Context) (error, bool) {
+	return nil, false
+}
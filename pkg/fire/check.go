@@ -0,0 +1,370 @@
+package fire
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rameshvk/fig/pkg/match"
+)
+
+// Check statically reports calls of constraints (see constraintf)
+// which can fail.  The expression should already be expanded (see
+// Expand).
+//
+// The possible values of each expression are tracked through where
+// bindings, `if` and calls of closures bound in where clauses.  A
+// constraint call is reported if any of the possible values of its
+// arg does not satisfy it:
+//
+//      list(unit(if(it.beta, 0.5, 2)), where(unit = constraint({ it >= 0 & it <= 1 })))
+//
+// Values which depend on `it`, streams, the clock or anything else
+// which is only known at runtime are not checked.  Neither are where
+// bindings which are never used.  The errors are
+// EvalErrors with the location of the call, sorted by location.
+func Check(ctx context.Context, v interface{}, scope Value) []error {
+	c := &checker{ctx: ctx, scope: scope, seen: map[string]bool{}}
+	c.eval(v, nil, 0)
+	sort.SliceStable(c.errs, func(i, j int) bool {
+		return c.errs[i].Location.Start < c.errs[j].Location.Start
+	})
+
+	result := make([]error, len(c.errs))
+	for kk, err := range c.errs {
+		result[kk] = err
+	}
+	return result
+}
+
+// Infer returns the possible values of an expression if they can be
+// determined statically (see Check):
+//
+//      if(it.beta, 0.5, "none")
+//
+// has the values 0.5 and "none".  It returns false if the values
+// are not known.
+func Infer(ctx context.Context, v interface{}, scope Value) ([]Value, bool) {
+	c := &checker{ctx: ctx, scope: scope, seen: map[string]bool{}}
+	result := c.eval(v, nil, 0)
+	return result, result != nil
+}
+
+const (
+	// maxChoices limits the number of possible values tracked for
+	// an expression
+	maxChoices = 16
+
+	// maxInline limits the nesting of closure calls which are
+	// checked with the values of their args
+	maxInline = 10
+)
+
+// dynamicGlobals are the globals which are never used statically:
+// streams can be modified and the time functions depend on the clock
+var dynamicGlobals = map[string]bool{"sys": true, "time": true}
+
+// choices are the possible values of an expression.  nil means the
+// value is not known statically.
+type choices []Value
+
+func (ch choices) add(ctx context.Context, v Value) choices {
+	for _, existing := range ch {
+		if existing.Equals(ctx, v) {
+			return ch
+		}
+	}
+	return append(ch, v)
+}
+
+type checker struct {
+	ctx   context.Context
+	scope Value
+	errs  []*EvalError
+	seen  map[string]bool
+}
+
+// staticEnv holds the bindings of where clauses (and closure args)
+type staticEnv struct {
+	parent   *staticEnv
+	bindings map[string]*staticBinding
+	depth    int
+}
+
+type staticBinding struct {
+	expr        interface{}
+	env         *staticEnv
+	value       choices
+	done, inUse bool
+}
+
+func (c *checker) report(loc Location, msg string) {
+	key := strconv.Itoa(loc.Start) + ":" + strconv.Itoa(loc.End) + ":" + msg
+	if !c.seen[key] {
+		c.seen[key] = true
+		c.errs = append(c.errs, &EvalError{Message: msg, Location: &loc})
+	}
+}
+
+func (c *checker) eval(v interface{}, env *staticEnv, depth int) choices {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return nil
+	}
+	head, _ := list[0].(string)
+	parts := strings.Split(head, ":")
+
+	switch parts[0] {
+	case "string", "number", "bool":
+		return c.known(Eval(c.ctx, v, c.scope))
+	case "name":
+		name, _ := list[1].(string)
+		result, _ := c.lookup(env, name)
+		return result
+	case "{}":
+		return c.closure(list, env, depth)
+	case "call":
+		return c.call(list, nodeLocation(parts), env, depth)
+	}
+	return c.apply(parts[0], nil, list[1:], nodeLocation(parts), env, depth)
+}
+
+// known returns the choices for a value which is known statically
+func (c *checker) known(v Value) choices {
+	if _, ok := v.Error(c.ctx); ok {
+		return nil
+	}
+	if _, ok := v.(*stream); ok {
+		return nil
+	}
+	return choices{v}
+}
+
+// lookup resolves a name, returning the binding if it is not a
+// global
+func (c *checker) lookup(env *staticEnv, name string) (choices, *staticBinding) {
+	for e := env; e != nil; e = e.parent {
+		if b, ok := e.bindings[name]; ok {
+			if !b.done && !b.inUse {
+				b.inUse = true
+				b.value = c.eval(b.expr, b.env, b.env.depth)
+				b.inUse = false
+				b.done = true
+			}
+			return b.value, b
+		}
+	}
+	if dynamicGlobals[name] {
+		return nil, nil
+	}
+	return c.known(c.scope.Lookup(c.ctx, stringValue(name))), nil
+}
+
+// bind creates the env for the where clauses in args.  If closure is
+// set, `it` and the assignments in args are also bound.  Bindings
+// are only checked when they are used.  The remaining args are
+// returned.
+func (c *checker) bind(env *staticEnv, args []interface{}, closure bool, it choices, depth int) (*staticEnv, []interface{}) {
+	inner := &staticEnv{env, map[string]*staticBinding{}, depth}
+	rest := []interface{}{}
+	add := func(arg interface{}) {
+		var name string
+		var value interface{}
+		pattern := match.Pattern([]interface{}{
+			match.StringPrefix("="),
+			[]interface{}{match.StringPrefix("string"), &name},
+			&value,
+		})
+		if pattern.Match(arg) == nil {
+			inner.bindings[name] = &staticBinding{expr: value, env: inner}
+		}
+	}
+
+	for _, arg := range args {
+		if wargs, ok := whereArgs(arg); ok {
+			for _, warg := range wargs {
+				add(warg)
+			}
+		} else if closure && (len(rest) > 0 || assignPattern.Match(arg) == nil) {
+			add(arg)
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	if closure {
+		inner.bindings["it"] = &staticBinding{value: it, done: true}
+	}
+	return inner, rest
+}
+
+// closure checks the body of the closure without knowing `it`.  The
+// value of the closure resolves names statically where possible.
+func (c *checker) closure(list []interface{}, env *staticEnv, depth int) choices {
+	inner, rest := c.bind(env, list[1:], true, nil, depth)
+	if len(rest) > 0 {
+		c.eval(rest[0], inner, depth)
+	}
+	return c.known(Eval(c.ctx, list, staticScope{newError("internal error"), c, env}))
+}
+
+func (c *checker) call(list []interface{}, loc Location, env *staticEnv, depth int) choices {
+	inner, args := c.bind(env, list[2:], false, nil, depth)
+
+	var name string
+	var fn choices
+	var binding *staticBinding
+	isName := match.Pattern([]interface{}{match.StringPrefix("name"), &name}).Match(list[1]) == nil
+	if isName {
+		fn, binding = c.lookup(env, name)
+	} else {
+		fn = c.eval(list[1], env, depth)
+	}
+
+	positional := len(args) == 1 && assignPattern.Match(args[0]) != nil
+	switch {
+	case isName && name == "if" && binding == nil && len(args) == 3:
+		return c.choose(args, inner, depth)
+	case binding != nil && isClosureExpression(binding.expr) && positional:
+		it := c.eval(args[0], inner, depth)
+		if depth >= maxInline || it == nil {
+			return nil
+		}
+		closure := binding.expr.([]interface{})
+		body, rest := c.bind(binding.env, closure[1:], true, it, depth+1)
+		if len(rest) == 0 {
+			return nil
+		}
+		return c.eval(rest[0], body, depth+1)
+	}
+	return c.apply("call", fn, args, loc, inner, depth)
+}
+
+// choose handles `if(condition, then, else)`
+func (c *checker) choose(args []interface{}, env *staticEnv, depth int) choices {
+	cond := c.eval(args[0], env, depth)
+	taken := map[bool]bool{}
+	for _, v := range cond {
+		b, ok := v.Bool(c.ctx)
+		if !ok {
+			cond = nil
+			break
+		}
+		taken[b] = true
+	}
+	if cond == nil {
+		taken = map[bool]bool{true: true, false: true}
+	}
+
+	result := choices{}
+	for kk, branch := range []bool{true, false} {
+		if !taken[branch] {
+			continue
+		}
+		values := c.eval(args[kk+1], env, depth)
+		if values == nil {
+			result = nil
+		}
+		for _, v := range values {
+			if result != nil {
+				result = result.add(c.ctx, v)
+			}
+		}
+	}
+	if len(result) > maxChoices {
+		return nil
+	}
+	return result
+}
+
+// apply evaluates the op (or call of fn) for all combinations of the
+// possible values of the args.  Calls of constraints are checked.
+func (c *checker) apply(op string, fn choices, args []interface{}, loc Location, env *staticEnv, depth int) choices {
+	nodes := make([]interface{}, len(args))
+	values := make([]choices, len(args))
+	unknown := op == "call" && fn == nil
+	for kk, arg := range args {
+		var name string
+		var value interface{}
+		pattern := match.Pattern([]interface{}{
+			match.StringPrefix("="),
+			[]interface{}{match.StringPrefix("string"), &name},
+			&value,
+		})
+		if pattern.Match(arg) == nil {
+			values[kk] = c.eval(value, env, depth)
+			nodes[kk] = []interface{}{"=", []interface{}{"string", name}, nil}
+		} else {
+			values[kk] = c.eval(arg, env, depth)
+		}
+		unknown = unknown || values[kk] == nil
+	}
+	if unknown {
+		return nil
+	}
+
+	if op == "call" && len(args) == 1 && nodes[0] == nil {
+		for _, f := range fn {
+			if cv, ok := f.(constraintValue); ok {
+				for _, v := range values[0] {
+					if b, ok := cv.test(c.ctx, v).Bool(c.ctx); ok && !b {
+						c.report(loc, cv.message(c.ctx, v))
+					}
+				}
+			}
+		}
+	}
+
+	if op == "call" {
+		nodes = append([]interface{}{nil}, nodes...)
+		values = append([]choices{fn}, values...)
+	}
+	result := choices{}
+	combos := [][]Value{{}}
+	for _, ch := range values {
+		next := [][]Value{}
+		for _, combo := range combos {
+			for _, v := range ch {
+				next = append(next, append(append([]Value(nil), combo...), v))
+			}
+		}
+		if combos = next; len(combos) > maxChoices {
+			return nil
+		}
+	}
+	for _, combo := range combos {
+		expr := []interface{}{op}
+		pairs := make([][2]Value, len(combo))
+		for kk, v := range combo {
+			name := "(arg " + strconv.Itoa(kk) + ")"
+			pairs[kk] = [2]Value{stringValue(name), v}
+			ref := []interface{}{"name", name}
+			if node, ok := nodes[kk].([]interface{}); ok {
+				ref = []interface{}{node[0], node[1], ref}
+			}
+			expr = append(expr, ref)
+		}
+		values := c.known(Eval(c.ctx, expr, Scope(c.ctx, c.scope, pairs...)))
+		if values == nil {
+			return nil
+		}
+		result = result.add(c.ctx, values[0])
+	}
+	return result
+}
+
+// staticScope looks up names in a staticEnv.  Only names with a
+// single known value are available.
+type staticScope struct {
+	Value
+	c   *checker
+	env *staticEnv
+}
+
+func (s staticScope) Lookup(ctx context.Context, field Value) Value {
+	name, _ := field.String(ctx)
+	if result, _ := s.c.lookup(s.env, name); len(result) == 1 {
+		return result[0]
+	}
+	return newError("not known statically: " + name)
+}
@@ -0,0 +1,137 @@
+package fire_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestConstraints(t *testing.T) {
+	ctx := context.Background()
+	suite := map[string]fire.Value{
+		`{pos(5), where(pos = constraint({it > 0}))}(0)`:                                                  fire.Number(5),
+		`{pos(-1), where(pos = constraint({it > 0}))}(0)`:                                                 fire.Error("-1 does not satisfy constraint({it > 0})"),
+		`{pos(error("boo")), where(pos = constraint({it > 0}))}(0)`:                                       fire.Error("boo"),
+		`{pos("x"), where(pos = constraint({it.y}))}(0)`:                                                  fire.Error("cannot lookup a string"),
+		`{pos(1), where(pos = constraint({it}))}(0)`:                                                      fire.Error("constraint predicate must return a bool"),
+		`{pos.test(-1), where(pos = constraint({it > 0}))}(0)`:                                            fire.Bool(false),
+		`{small(5), where(pos = constraint({it > 0}), small = constraint({pos.test(it) & it < 10}))}(0)`:  fire.Number(5),
+		`{small(-5), where(pos = constraint({it > 0}), small = constraint({pos.test(it) & it < 10}))}(0)`: fire.Error("-5 does not satisfy constraint({pos.test(it) & it < 10})"),
+		`constraint({it > 0}) == constraint({it > 0})`:                                                    fire.Bool(true),
+		`constraint({it > 0}).boo`:                                                                        fire.Error("field not found: \"boo\""),
+		`constraint(predicate = {it > 0}) == constraint({it > 0})`:                                        fire.Bool(true),
+		`constraint()`: fire.Error("constraint requires a predicate"),
+	}
+
+	for code, expected := range suite {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, expected) {
+			t.Error("Unexpected", code, got.Code(ctx), expected.Code(ctx))
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := fire.WithLimits(context.Background(), fire.DefaultLimits)
+	unit := `unit = constraint({it >= 0 & it <= 1})`
+	suite := map[string][]string{
+		`{unit(0.5), where(` + unit + `)}(it)`:                             nil,
+		`{unit(it.x), where(` + unit + `)}(it)`:                            nil,
+		`{unit(2), where(` + unit + `)}(it)`:                               {"2 does not satisfy"},
+		`{unit(if(it.beta, 0.5, 2)), where(` + unit + `)}(it)`:             {"2 does not satisfy"},
+		`{unit(if(true, 0.5, 2)), where(` + unit + `)}(it)`:                nil,
+		`{unit(half * 3), where(half = 0.5, ` + unit + `)}(it)`:            {"1.5 does not satisfy"},
+		`{unit(math.max(x, 3)), where(x = 0, ` + unit + `)}(it)`:           {"3 does not satisfy"},
+		`{unit(double(0.75)), where(double = {it * 2}, ` + unit + `)}(it)`: {"1.5 does not satisfy"},
+
+		// constraints within closures are checked with the args
+		`{f(0.25) + f(0.75), where(f = {unit(it * 2)}, ` + unit + `)}(it)`: {"1.5 does not satisfy"},
+		`{f(it), where(f = {unit(it * 2)}, ` + unit + `)}(it)`:             nil,
+
+		// closures which are not called are checked without it
+		`{{unit(it + 2)}, where(` + unit + `)}(it)`:    nil,
+		`{{unit(-1)}, where(` + unit + `)}(it)`:        {"-1 does not satisfy"},
+		`{f(1), where(f = {f(it)}, ` + unit + `)}(it)`: nil,
+
+		// streams are never used statically
+		`{unit(sys.streams.new(2)), where(` + unit + `)}(it)`: nil,
+
+		// the clock is only known at runtime
+		`list(launched(time.now() > time.parse("2027-01-01T00:00:00Z")), where(launched = constraint({it})))`: nil,
+		`list(unit(time.ramp(start = "2019-10-01", end = "2019-10-08") * 2), where(` + unit + `))`:            nil,
+
+		// where clauses in the args do not scope over the callee
+		`list(unit(if(it.beta, 0.5, 2)), where(` + unit + `))`: {"2 does not satisfy"},
+		`unit(if(it.beta, 0.5, 2), where(` + unit + `))`:       nil,
+
+		// bindings are only checked when used
+		`list(1, where(bad = unit(2), ` + unit + `))`:   nil,
+		`list(bad, where(bad = unit(2), ` + unit + `))`: {"2 does not satisfy"},
+	}
+
+	for code, expected := range suite {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		errs = fire.Check(ctx, parsed, fire.Globals())
+		if len(errs) != len(expected) {
+			t.Error("Unexpected errors", code, errs)
+			continue
+		}
+		for kk, err := range errs {
+			if !strings.HasPrefix(err.Error(), expected[kk]) {
+				t.Error("Unexpected error", code, err)
+			}
+		}
+	}
+}
+
+func TestCheckLocation(t *testing.T) {
+	ctx := context.Background()
+	code := `if(it.x, unit(0.5), unit(2), where(unit = constraint({it <= 1})))`
+	parsed, _ := parse.String(code)
+	errs := fire.Check(ctx, parsed, fire.Globals())
+	if len(errs) != 1 {
+		t.Fatal("Unexpected errors", errs)
+	}
+	loc := errs[0].(*fire.EvalError).Location
+	if code[loc.Start-4:loc.Start+2] != "unit(2" {
+		t.Error("Unexpected location", loc)
+	}
+
+	// the same error is reported at runtime
+	result := fire.Eval(ctx, parsed, fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("it"), fire.FromNative(ctx, map[string]interface{}{"x": false})}))
+	if err, ok := result.Error(ctx); !ok || err.Error() != errs[0].Error() {
+		t.Error("Unexpected runtime error", err, errs[0])
+	}
+}
+
+func TestInfer(t *testing.T) {
+	ctx := fire.WithLimits(context.Background(), fire.DefaultLimits)
+	suite := map[string][]fire.Value{
+		`if(it.beta, 0.5, "none")`:          {fire.Number(0.5), fire.String("none")},
+		`if(it.x, 1, if(it.y, 2, 1)) * 10`:  {fire.Number(10), fire.Number(20)},
+		`list(1, x, where(x = "a")).length`: {fire.Number(2)},
+		`it.x + 1`:                          nil,
+		`if(it.beta, 0.5, it.x)`:            nil,
+		`{it * 2}(3)`:                       {fire.Number(6)},
+	}
+
+	for code, expected := range suite {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		got, ok := fire.Infer(ctx, parsed, fire.Globals())
+		if ok != (expected != nil) || !fire.List(got...).Equals(ctx, fire.List(expected...)) {
+			t.Error("Unexpected", code, got, ok)
+		}
+	}
+}
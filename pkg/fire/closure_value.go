@@ -2,29 +2,42 @@ package fire
 
 import (
 	"context"
+	"reflect"
+	"strings"
+
+	"github.com/rameshvk/fig/pkg/parse"
 )
 
 // Closure creates a closure of an expression and associated scope
 func Closure(expression interface{}, scope Value) Value {
-	return closureValue{expression, scope}
+	return closureValue{expression, scope, nil}
 }
 
 type closureValue struct {
 	expression interface{}
 	scope      Value
+
+	// args are the original args of the closure expression,
+	// including the where clauses, if available
+	args []interface{}
 }
 
+// Code formats the closure expression.  Names bound outside the
+// closure are not included.
 func (c closureValue) Code(ctx context.Context) string {
-	panic("not yet implemented")
+	if c.args != nil {
+		return parse.Format(append([]interface{}{"{}"}, c.args...))
+	}
+	return "{" + parse.Format(c.expression) + "}"
 }
 
 func (c closureValue) HashCode() interface{} {
-	return closureValue{}
+	return "{}"
 }
 
 func (c closureValue) Call(ctx context.Context, args ...Value) Value {
 	if len(args) != 1 {
-		return errorValue("closures always take one arg")
+		return newError("closures always take one arg")
 	}
 	it := Scope(ctx, c.scope, [2]Value{stringValue("it"), args[0]})
 	return Eval(ctx, c.expression, it)
@@ -32,12 +45,82 @@ func (c closureValue) Call(ctx context.Context, args ...Value) Value {
 
 func (c closureValue) Lookup(ctx context.Context, field Value) Value {
 	// TODO: add closure methods
-	return errorValue("cannot lookup a closure")
+	return newError("cannot lookup a closure")
 }
 
+// Equals compares the code of the closures as well as the values
+// of the names they use from the enclosing scope, so `{it + y}` is
+// only equal to another `{it + y}` with the same `y`.
 func (c closureValue) Equals(ctx context.Context, other Value) bool {
-	// TODO: simplify this by using hash keys
-	return c.Code(ctx) == other.Code(ctx)
+	o, ok := other.(closureValue)
+	if !ok || c.Code(ctx) != o.Code(ctx) {
+		return false
+	}
+
+	// closures which refer to each other via their scopes are
+	// assumed to be equal while they are being compared
+	pair := [2]uintptr{identity(c.scope), identity(o.scope)}
+	if pair[0] != 0 && pair[0] == pair[1] {
+		return true
+	}
+	comparing, _ := ctx.Value(comparingKey{}).(map[[2]uintptr]bool)
+	if comparing[pair] {
+		return true
+	}
+	if comparing == nil {
+		comparing = map[[2]uintptr]bool{}
+		ctx = context.WithValue(ctx, comparingKey{}, comparing)
+	}
+	comparing[pair] = true
+	defer delete(comparing, pair)
+
+	for _, name := range c.names() {
+		key := stringValue(name)
+		if !c.scope.Lookup(ctx, key).Equals(ctx, o.scope.Lookup(ctx, key)) {
+			return false
+		}
+	}
+	return true
+}
+
+type comparingKey struct{}
+
+// names returns the names used by the closure other than `it`
+func (c closureValue) names() []string {
+	seen := map[string]bool{"it": true}
+	result := []string{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		list, ok := v.([]interface{})
+		if !ok || len(list) == 0 {
+			return
+		}
+		if head, _ := list[0].(string); strings.HasPrefix(head, "name") && len(list) == 2 {
+			if name, ok := list[1].(string); ok && !seen[name] {
+				seen[name] = true
+				result = append(result, name)
+			}
+			return
+		}
+		for _, elt := range list[1:] {
+			walk(elt)
+		}
+	}
+	walk(c.expression)
+	for _, arg := range c.args {
+		walk(arg)
+	}
+	return result
+}
+
+// identity returns the address of scopes and other reference types
+// and zero otherwise
+func identity(v Value) uintptr {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Map {
+		return rv.Pointer()
+	}
+	return 0
 }
 
 func (c closureValue) Number(ctx context.Context) (float64, bool) {
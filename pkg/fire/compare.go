@@ -0,0 +1,170 @@
+package fire
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// compare orders any two values, returning -1, 0 or 1.
+//
+// Values of different types are ordered by type: booleans, numbers
+// (including times), strings, lists, objects and then everything
+// else.  Within a type, false < true, numbers are compared
+// numerically (with NaN before all other numbers), strings
+// lexically (by bytes) and lists element-wise with shorter lists
+// first.  Objects and other values are ordered by their code.
+//
+// If either value is an error, that error is returned instead.
+func compare(ctx context.Context, a, b Value) (int, Value) {
+	if _, ok := a.Error(ctx); ok {
+		return 0, a
+	}
+	if _, ok := b.Error(ctx); ok {
+		return 0, b
+	}
+
+	ra, rb := rank(ctx, a), rank(ctx, b)
+	if ra != rb {
+		return sign(ra - rb), nil
+	}
+
+	switch ra {
+	case rankBool:
+		x, _ := a.Bool(ctx)
+		y, _ := b.Bool(ctx)
+		return sign(btoi(x) - btoi(y)), nil
+	case rankNumber:
+		x, _ := a.Number(ctx)
+		y, _ := b.Number(ctx)
+		switch {
+		case math.IsNaN(x) || math.IsNaN(y):
+			return sign(btoi(!math.IsNaN(x)) - btoi(!math.IsNaN(y))), nil
+		case x < y:
+			return -1, nil
+		case x > y:
+			return 1, nil
+		}
+		return 0, nil
+	case rankString:
+		x, _ := a.String(ctx)
+		y, _ := b.String(ctx)
+		return strings.Compare(x, y), nil
+	case rankList:
+		x, _ := asList(a)
+		y, _ := asList(b)
+		for kk := 0; kk < len(x) && kk < len(y); kk++ {
+			if c, err := compare(ctx, x[kk], y[kk]); c != 0 || err != nil {
+				return c, err
+			}
+		}
+		return sign(len(x) - len(y)), nil
+	}
+	return strings.Compare(a.Code(ctx), b.Code(ctx)), nil
+}
+
+const (
+	rankBool = iota
+	rankNumber
+	rankString
+	rankList
+	rankObject
+	rankOther
+)
+
+func rank(ctx context.Context, v Value) int {
+	if _, ok := v.Bool(ctx); ok {
+		return rankBool
+	}
+	if _, ok := v.Number(ctx); ok {
+		return rankNumber
+	}
+	if _, ok := v.String(ctx); ok {
+		return rankString
+	}
+	if _, ok := asList(v); ok {
+		return rankList
+	}
+	switch v.(type) {
+	case obj, nativeValue:
+		return rankObject
+	}
+	return rankOther
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// compareOp creates a comparison operator
+func compareOp(fn func(c int) bool) func(ctx context.Context, args ...Value) Value {
+	return func(ctx context.Context, args ...Value) Value {
+		if len(args) != 2 {
+			return newError("operator requires two args")
+		}
+		c, err := compare(ctx, args[0], args[1])
+		return checkError(boolValue(fn(c)), err)
+	}
+}
+
+// compareVersions compares dotted version strings such as "2.10.1"
+// returning -1, 0 or 1.  Parts which are numbers are compared
+// numerically and other parts lexically, so "2.10" > "2.9".
+// Missing parts are treated as zero: "2.3" == "2.3.0".  An optional
+// leading "v" is ignored.
+func compareVersions(ctx context.Context, args []Value) Value {
+	var parts [2][]string
+	for kk, arg := range args {
+		if _, ok := arg.Error(ctx); ok {
+			return arg
+		}
+		s, ok := arg.String(ctx)
+		if !ok {
+			return newError("strings.compareVersions requires strings")
+		}
+		parts[kk] = strings.Split(strings.TrimPrefix(s, "v"), ".")
+	}
+
+	x, y := parts[0], parts[1]
+	for kk := 0; kk < len(x) || kk < len(y); kk++ {
+		px, py := "0", "0"
+		if kk < len(x) {
+			px = x[kk]
+		}
+		if kk < len(y) {
+			py = y[kk]
+		}
+
+		nx, errx := strconv.Atoi(px)
+		ny, erry := strconv.Atoi(py)
+		c := 0
+		switch {
+		case errx == nil && erry == nil:
+			c = sign(nx - ny)
+		case errx == nil:
+			c = -1 // numbers before other parts
+		case erry == nil:
+			c = 1
+		default:
+			c = strings.Compare(px, py)
+		}
+		if c != 0 {
+			return numberValue(c)
+		}
+	}
+	return numberValue(0)
+}
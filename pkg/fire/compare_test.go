@@ -0,0 +1,54 @@
+package fire_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestCompare(t *testing.T) {
+	ctx := context.Background()
+	b, n := fire.Bool, fire.Number
+
+	suite := map[string]fire.Value{
+		`1 < 2`:                                      b(true),
+		`2 <= 2`:                                     b(true),
+		`"apple" < "banana"`:                         b(true),
+		`"b" >= "a"`:                                 b(true),
+		`"2.10.0" > "2.9.0"`:                         b(false),
+		`false < true`:                               b(true),
+		`true < 0`:                                   b(true),
+		`5 < "5"`:                                    b(true),
+		`"z" < list()`:                               b(true),
+		`list(1, 2) < list(1, 3)`:                    b(true),
+		`list(1, 2) < list(1, 2, 0)`:                 b(true),
+		`list(1, 3) > list(1, 2, 5)`:                 b(true),
+		`list(1, 2) <= list(1, 2)`:                   b(true),
+		`list() < object(x = 1)`:                     b(true),
+		`object(x = 1) < object(x = 2)`:              b(true),
+		`math.NaN < 0 - math.Inf`:                    b(true),
+		`time.unix(5) < 6`:                           b(true),
+		`error("boo") < 1`:                           fire.Error("boo"),
+		`1 < list(error("boo"))`:                     b(true),
+		`list(1) < list(error("boo"))`:               fire.Error("boo"),
+		`list("b", "a", "c").sort().(0)`:             fire.String("a"),
+		`strings.compareVersions("2.10.0", "2.9.1")`: n(1),
+		`strings.compareVersions("v2.3", "2.3.0")`:   n(0),
+		`strings.compareVersions(x = "1.0.0", y = "1.0.1")`: n(-1),
+		`strings.compareVersions("1.0.beta", "1.0.1")`:      n(1),
+		`strings.compareVersions("1.0.alpha", "1.0.beta")`:  n(-1),
+		`strings.compareVersions("1.0", 1)`:                 fire.Error("strings.compareVersions requires strings"),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+}
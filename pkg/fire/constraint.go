@@ -0,0 +1,111 @@
+package fire
+
+import (
+	"context"
+)
+
+// constraintf creates a constraint from a predicate:
+//
+//      isPositive = constraint({ it > 0 })
+//
+// Calling a constraint returns its arg if the predicate holds and an
+// error otherwise.  Calls of constraints are also checked statically
+// by Check.
+func constraintf(ctx context.Context, args ...Value) Value {
+	predicate, ok := methodArg(args, "predicate")
+	if !ok {
+		return newError("constraint requires a predicate")
+	}
+	if _, ok := predicate.Error(ctx); ok {
+		return predicate
+	}
+	return constraintValue{predicate}
+}
+
+type constraintValue struct {
+	predicate Value
+}
+
+func (c constraintValue) Code(ctx context.Context) string {
+	return "constraint(" + c.predicate.Code(ctx) + ")"
+}
+
+func (c constraintValue) HashCode() interface{} {
+	return "constraint"
+}
+
+// Call returns the arg if it satisfies the constraint.  Errors are
+// passed through unchanged.
+func (c constraintValue) Call(ctx context.Context, args ...Value) Value {
+	if len(args) != 1 {
+		return newError("constraints always take one arg")
+	}
+	return lift(func(ctx context.Context, args ...Value) Value {
+		if _, ok := args[0].Error(ctx); ok {
+			return args[0]
+		}
+		result := c.test(ctx, args[0])
+		if b, ok := result.Bool(ctx); ok && !b {
+			return newError(c.message(ctx, args[0]))
+		}
+		if _, ok := result.Error(ctx); ok {
+			return result
+		}
+		return args[0]
+	})(ctx, args...)
+}
+
+// test calls the predicate, returning a bool or an error
+func (c constraintValue) test(ctx context.Context, v Value) Value {
+	result := c.predicate.Call(ctx, v)
+	if _, ok := result.Error(ctx); ok {
+		return result
+	}
+	if b, ok := result.Bool(ctx); ok {
+		return boolValue(b)
+	}
+	return newError("constraint predicate must return a bool")
+}
+
+func (c constraintValue) message(ctx context.Context, v Value) string {
+	return v.Code(ctx) + " does not satisfy " + c.Code(ctx)
+}
+
+// Lookup supports the `test` method which checks if a value
+// satisfies the constraint:
+//
+//      isSmall = constraint({ isPositive.test(it) & it < 10 })
+func (c constraintValue) Lookup(ctx context.Context, field Value) Value {
+	name, _ := field.String(ctx)
+	if name == "test" {
+		return method(c, name, lift(func(ctx context.Context, args ...Value) Value {
+			v, ok := methodArg(args, "it")
+			if !ok {
+				return newError("missing it")
+			}
+			return c.test(ctx, v)
+		}))
+	}
+	return newError("field not found: " + field.Code(ctx))
+}
+
+func (c constraintValue) Equals(ctx context.Context, other Value) bool {
+	o, ok := other.(constraintValue)
+	return ok && o.predicate.Equals(ctx, c.predicate)
+}
+
+func (c constraintValue) Number(ctx context.Context) (float64, bool) {
+	return 0, false
+}
+
+func (c constraintValue) String(ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (c constraintValue) Bool(ctx context.Context) (bool, bool) {
+	return false, false
+}
+
+func (c constraintValue) Error(ctx context.Context) (error, bool) {
+	return nil, false
+}
@@ -2,9 +2,11 @@ package fire_test
 
 import (
 	"context"
+	"reflect"
 	"testing"
 
 	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
 )
 
 func TestError(t *testing.T) {
@@ -50,3 +52,33 @@ func TestError(t *testing.T) {
 		t.Fatal("error check failed")
 	}
 }
+
+func TestErrorLocation(t *testing.T) {
+	ctx := context.Background()
+	parsed, errs := parse.String(`if(true, { it.x }(5), 2)`)
+	if len(errs) > 0 {
+		t.Fatal("Unexpected parse error", errs)
+	}
+
+	v := fire.Eval(ctx, parsed, fire.Globals())
+	if !v.Equals(ctx, fire.Error("cannot lookup a number")) {
+		t.Fatal("Unexpected value", v)
+	}
+
+	err, _ := fire.ToNative(ctx, v).(*fire.EvalError)
+	expected := &fire.EvalError{
+		Message:  "cannot lookup a number",
+		Location: &fire.Location{Start: 13, End: 14},
+		Stack:    []fire.Location{{Start: 17, End: 17}, {Start: 2, End: 2}},
+	}
+	if !reflect.DeepEqual(err, expected) {
+		t.Fatal("Unexpected error", err)
+	}
+	if err.Error() != "cannot lookup a number (at 13:14)" {
+		t.Fatal("Unexpected message", err.Error())
+	}
+
+	if v := fire.FromNative(ctx, err); !reflect.DeepEqual(fire.ToNative(ctx, v), expected) {
+		t.Fatal("Unexpected roundtrip", v)
+	}
+}
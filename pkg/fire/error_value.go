@@ -2,21 +2,32 @@ package fire
 
 import (
 	"context"
+	"fmt"
 )
 
 // Error converts a string into an error value
 func Error(s string) Value {
-	return errorValue(s)
+	return newError(s)
 }
 
-type errorValue string
+func newError(s string) errorValue {
+	return errorValue{msg: s}
+}
+
+// errorValue is an error with an optional source location.  The
+// location is filled in by Eval (see locate) and is ignored when
+// comparing errors.
+type errorValue struct {
+	msg     string
+	located *EvalError
+}
 
 func (e errorValue) Code(ctx context.Context) string {
-	return `error(` + stringValue(e).Code(ctx) + `)`
+	return `error(` + stringValue(e.msg).Code(ctx) + `)`
 }
 
 func (e errorValue) HashCode() interface{} {
-	return e
+	return newError(e.msg)
 }
 
 func (e errorValue) Call(ctx context.Context, args ...Value) Value {
@@ -28,7 +39,8 @@ func (e errorValue) Lookup(ctx context.Context, field Value) Value {
 }
 
 func (e errorValue) Equals(ctx context.Context, other Value) bool {
-	return e == other
+	o, ok := other.(errorValue)
+	return ok && o.msg == e.msg
 }
 
 func (e errorValue) Number(ctx context.Context) (float64, bool) {
@@ -44,11 +56,51 @@ func (e errorValue) Bool(ctx context.Context) (bool, bool) {
 }
 
 func (e errorValue) Error(ctx context.Context) (error, bool) {
-	return fireError(string(e)), true
+	if e.located == nil {
+		return &EvalError{Message: e.msg}, true
+	}
+	result := *e.located
+	return &result, true
 }
 
-type fireError string
+// locate records the source range of the expression which produced
+// the error.  If the error already has a location, the range is
+// added to the stack of enclosing calls instead.
+func (e errorValue) locate(loc Location, call bool) errorValue {
+	if e.located == nil {
+		return errorValue{e.msg, &EvalError{Message: e.msg, Location: &loc}}
+	}
+	if !call {
+		return e
+	}
+	located := *e.located
+	located.Stack = append(append([]Location(nil), located.Stack...), loc)
+	return errorValue{e.msg, &located}
+}
+
+// Location is a range of byte offsets into the source of an
+// expression.  See EvalError.
+type Location struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// EvalError is the error returned by ToNative for error values.
+//
+// Errors produced during Eval carry the location of the innermost
+// expression that failed as well as the locations of the calls the
+// error propagated through, innermost first.  The location is nil for
+// errors which did not come from Eval (such as those created by
+// Error).
+type EvalError struct {
+	Message  string
+	Location *Location
+	Stack    []Location
+}
 
-func (f fireError) Error() string {
-	return string(f)
+func (e *EvalError) Error() string {
+	if e.Location == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s (at %d:%d)", e.Message, e.Location.Start, e.Location.End)
 }
@@ -2,23 +2,55 @@ package fire
 
 import (
 	"context"
+	"strconv"
 	"strings"
 
 	"github.com/rameshvk/fig/pkg/match"
 )
 
 // Eval evaluates a fig list expression
+//
+// Evaluation stops with an error value if the context is cancelled
+// or if the limits set with WithLimits are exceeded.
 func Eval(ctx context.Context, v interface{}, scope Value) Value {
 	list, ok := v.([]interface{})
 	if !ok || len(list) == 0 {
-		return errorValue("invalid list expression")
+		return newError("invalid list expression")
 	}
 
 	s := list[0].(string)
 	parts := strings.Split(s, ":")
 
-	// TODO: save the location into ctx for error reporting
-	return builtin(ctx, parts[0], list[1:], scope)
+	result := eval(ctx, parts, list[1:], scope)
+	if err, ok := result.(errorValue); ok && len(parts) == 3 {
+		return err.locate(location(parts), parts[0] == "call")
+	}
+	return result
+}
+
+// eval checks the budget and evaluates a single list expression
+func eval(ctx context.Context, parts []string, args []interface{}, scope Value) Value {
+	if err := cancelled(ctx); err != nil {
+		return err
+	}
+	if b, ok := ctx.Value(budgetKey{}).(*budget); ok {
+		if err := b.enter(); err != nil {
+			return err
+		}
+		defer b.exit()
+	}
+
+	if t, ok := ctx.Value(traceKey{}).(*Trace); ok {
+		return t.eval(ctx, parts, args, scope)
+	}
+	return builtin(ctx, parts[0], args, scope)
+}
+
+// location parses the source range from the list expression head
+func location(parts []string) Location {
+	start, _ := strconv.Atoi(parts[1])
+	end, _ := strconv.Atoi(parts[2])
+	return Location{start, end}
 }
 
 func builtin(ctx context.Context, s string, args []interface{}, scope Value) Value {
@@ -60,7 +92,7 @@ func builtin(ctx context.Context, s string, args []interface{}, scope Value) Val
 		return fn.Call(ctx, values...)
 	}
 
-	return errorValue("invalid expression")
+	return newError("invalid expression")
 }
 
 func call(ctx context.Context, args []interface{}, outer Value) Value {
@@ -89,6 +121,10 @@ func callValue(ctx context.Context, fn Value, args []interface{}, scope Value) V
 		if errv != nil {
 			return errv
 		}
+		switch fn.(type) {
+		case methodFn, *stream:
+			arg = namedArgs{arg.(obj)}
+		}
 	}
 	return fn.Call(ctx, arg)
 }
@@ -102,7 +138,7 @@ func evalArgument(ctx context.Context, args []interface{}, scope Value) (Value,
 		if err := p.Match(arg); err == nil {
 			key := stringValue(name)
 			if _, ok := o[key]; ok {
-				return nil, errorValue("duplicate name")
+				return nil, newError("duplicate name")
 			}
 			o[key] = scope.Lookup(ctx, key)
 		} else if err := accumulateAssign(inner, arg); err != nil {
@@ -122,6 +158,7 @@ func evalArgument(ctx context.Context, args []interface{}, scope Value) (Value,
 }
 
 func closure(ctx context.Context, args []interface{}, outer Value) Value {
+	source := args
 	args, scope, err := filterArgs(args)
 	if err != nil {
 		return err
@@ -135,17 +172,17 @@ func closure(ctx context.Context, args []interface{}, outer Value) Value {
 		}
 	}
 	if result == nil {
-		return errorValue("no expression provided")
+		return newError("no expression provided")
 	}
 	if _, ok := scope[stringValue("it")]; ok {
-		return errorValue("cannot define value for it")
+		return newError("cannot define value for it")
 	}
 
 	s := newScope(outer)
 	for k, v := range scope {
 		s.add(ctx, k, v)
 	}
-	return closureValue{result, s}
+	return closureValue{result, s, source}
 }
 
 var zero = []interface{}{"number:0:1", float64(0)}
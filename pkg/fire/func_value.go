@@ -28,7 +28,7 @@ func (f funcValue) Call(ctx context.Context, args ...Value) Value {
 
 func (f funcValue) Lookup(ctx context.Context, field Value) Value {
 	// TODO: add methods
-	return errorValue("cannot lookup a function")
+	return newError("cannot lookup a function")
 }
 
 func (f funcValue) Equals(ctx context.Context, other Value) bool {
@@ -2,7 +2,6 @@ package fire
 
 import (
 	"context"
-	"math"
 )
 
 // Globals returns the standard globals
@@ -12,8 +11,16 @@ import (
 //   all standard operators
 //   error(string)
 //   if(condition, then, else)
+//   constraint(predicate), see Check
 //   object(key: value, ....)
-//   math.Inf
+//   list(value, ....)
+//   macro(body), only within where clauses (see Expand)
+//   strings.hash(value, seed)
+//   time.now(), time.parse(s), time.ramp(start, end) etc
+//   math.Inf, math.floor(x), math.min(x, ...) etc
+//   net.ip(s).inCIDR(cidr), net.cidrs(cidr, ...)
+//   semver.compare(x, y), semver.satisfies(version, range) etc
+//   sys.streams.new(s), sys.streams.snapshot(s) etc
 //
 func Globals() Value {
 	code := func(c string) func(ctx context.Context) string {
@@ -23,34 +30,41 @@ func Globals() Value {
 	}
 
 	return Object(map[Value]Value{
-		String("+"):      Function(code("+"), add),
-		String("-"):      Function(code("-"), sub),
-		String("*"):      Function(code("*"), mul),
-		String("/"):      Function(code("/"), div),
-		String("<"):      Function(code("<"), less),
-		String("<="):     Function(code("<="), notGreater),
-		String(">"):      Function(code(">"), greater),
-		String(">="):     Function(code(">="), notLess),
-		String("=="):     Function(code("=="), equals),
-		String("!="):     Function(code("=="), notEquals),
-		String("&"):      NativeFunction(code("&"), and),
-		String("|"):      NativeFunction(code("|"), or),
-		String("!"):      Function(code("!"), not),
-		String("."):      Function(code("."), field),
-		String("{}"):     NativeFunction(code("{}"), closure),
-		String("call"):   NativeFunction(code("()"), call),
-		String("error"):  Function(code("error"), errorf),
-		String("if"):     NativeFunction(code("if"), nativeIf),
-		String("object"): Function(code("object"), objectf),
-		String("math"): Object(map[Value]Value{
-			String("Inf"): Number(math.Inf(+1)),
-		}),
+		String("+"):          Function(code("+"), lift(add)),
+		String("-"):          Function(code("-"), lift(sub)),
+		String("*"):          Function(code("*"), lift(mul)),
+		String("/"):          Function(code("/"), lift(div)),
+		String("%"):          Function(code("%"), lift(mod)),
+		String("<"):          Function(code("<"), lift(compareOp(func(c int) bool { return c < 0 }))),
+		String("<="):         Function(code("<="), lift(compareOp(func(c int) bool { return c <= 0 }))),
+		String(">"):          Function(code(">"), lift(compareOp(func(c int) bool { return c > 0 }))),
+		String(">="):         Function(code(">="), lift(compareOp(func(c int) bool { return c >= 0 }))),
+		String("=="):         Function(code("=="), lift(equals)),
+		String("!="):         Function(code("=="), lift(notEquals)),
+		String("&"):          NativeFunction(code("&"), and),
+		String("|"):          NativeFunction(code("|"), or),
+		String("!"):          Function(code("!"), lift(not)),
+		String("."):          Function(code("."), field),
+		String("{}"):         NativeFunction(code("{}"), closure),
+		String("call"):       NativeFunction(code("()"), call),
+		String("error"):      Function(code("error"), lift(errorf)),
+		String("constraint"): methodFunction(code("constraint"), constraintf),
+		String("if"):         NativeFunction(code("if"), nativeIf),
+		String("object"):     Function(code("object"), objectf),
+		String("list"):       NativeFunction(code("list"), listf),
+		String("macro"):      NativeFunction(code("macro"), macrof),
+		String("math"):       mathObject(),
+		String("net"):        netObject(),
+		String("semver"):     semverObject(),
+		String("sys"):        sysObject(),
+		String("strings"):    stringsObject(code),
+		String("time"):       timeObject(),
 	})
 }
 
 func and(ctx context.Context, args []interface{}, scope Value) Value {
 	if len(args) != 2 {
-		return errorValue("& requires two args")
+		return newError("& requires two args")
 	}
 
 	left := Eval(ctx, args[0], scope)
@@ -65,7 +79,7 @@ func and(ctx context.Context, args []interface{}, scope Value) Value {
 
 func or(ctx context.Context, args []interface{}, scope Value) Value {
 	if len(args) != 2 {
-		return errorValue("| requires two args")
+		return newError("| requires two args")
 	}
 
 	left := Eval(ctx, args[0], scope)
@@ -83,7 +97,7 @@ func or(ctx context.Context, args []interface{}, scope Value) Value {
 
 func not(ctx context.Context, args ...Value) Value {
 	if len(args) != 1 {
-		return errorValue("operator requires one arg")
+		return newError("operator requires one arg")
 	}
 
 	if _, ok := args[0].Error(ctx); ok {
@@ -99,7 +113,7 @@ func not(ctx context.Context, args ...Value) Value {
 
 func field(ctx context.Context, args ...Value) Value {
 	if len(args) != 2 {
-		return errorValue("operator requires two args")
+		return newError("operator requires two args")
 	}
 
 	return args[0].Lookup(ctx, args[1])
@@ -107,7 +121,7 @@ func field(ctx context.Context, args ...Value) Value {
 
 func numericArgs(ctx context.Context, args []Value) (float64, float64, Value) {
 	if len(args) != 2 {
-		return 0, 0, errorValue("operator requires two args")
+		return 0, 0, newError("operator requires two args")
 	}
 	if _, ok := args[0].Error(ctx); ok {
 		return 0, 0, args[0]
@@ -117,11 +131,11 @@ func numericArgs(ctx context.Context, args []Value) (float64, float64, Value) {
 	}
 	f1, ok := args[0].Number(ctx)
 	if !ok {
-		return 0, 0, errorValue("not a number")
+		return 0, 0, newError("not a number")
 	}
 	f2, ok := args[1].Number(ctx)
 	if !ok {
-		return 0, 0, errorValue("not a number")
+		return 0, 0, newError("not a number")
 	}
 
 	return f1, f2, nil
@@ -147,26 +161,6 @@ func div(ctx context.Context, args ...Value) Value {
 	return checkError(numberValue(f1/f2), err)
 }
 
-func less(ctx context.Context, args ...Value) Value {
-	f1, f2, err := numericArgs(ctx, args)
-	return checkError(boolValue(f1 < f2), err)
-}
-
-func notLess(ctx context.Context, args ...Value) Value {
-	f1, f2, err := numericArgs(ctx, args)
-	return checkError(boolValue(f1 >= f2), err)
-}
-
-func greater(ctx context.Context, args ...Value) Value {
-	f1, f2, err := numericArgs(ctx, args)
-	return checkError(boolValue(f1 > f2), err)
-}
-
-func notGreater(ctx context.Context, args ...Value) Value {
-	f1, f2, err := numericArgs(ctx, args)
-	return checkError(boolValue(f1 <= f2), err)
-}
-
 func equals(ctx context.Context, args ...Value) Value {
 	return boolValue(args[0].Equals(ctx, args[1]))
 }
@@ -178,23 +172,30 @@ func notEquals(ctx context.Context, args ...Value) Value {
 func errorf(ctx context.Context, args ...Value) Value {
 	if len(args) == 1 {
 		if s, ok := args[0].String(ctx); ok {
-			return errorValue(s)
+			return newError(s)
 		}
 	}
 
-	return errorValue("error() takes one string only")
+	return newError("error() takes one string only")
 }
 
 func objectf(ctx context.Context, args ...Value) Value {
 	if len(args) != 1 {
-		return errorValue("object() takes one arg only")
+		return newError("object() takes one arg only")
+	}
+	if o, ok := args[0].(obj); ok {
+		for _, v := range o {
+			if _, ok := v.(*stream); ok {
+				return objectStream(o)
+			}
+		}
 	}
 	return args[0]
 }
 
 func nativeIf(ctx context.Context, args []interface{}, scope Value) Value {
 	if len(args) != 3 {
-		return errorValue("if requires 3 unnamed args")
+		return newError("if requires 3 unnamed args")
 	}
 	condition := Eval(ctx, args[0], scope)
 	if b, ok := condition.Bool(ctx); b && ok {
@@ -0,0 +1,70 @@
+package fire
+
+import "context"
+
+// Limits restricts the amount of work done by Eval.  A zero field
+// means no limit.  See WithLimits.
+type Limits struct {
+	// Steps is the maximum number of list expressions evaluated
+	Steps int
+
+	// Depth is the maximum nesting of list expressions being
+	// evaluated, including those in the body of closures
+	Depth int
+}
+
+// DefaultLimits are generous limits meant for evaluating untrusted
+// expressions.  Typical config entries take a few dozen steps.
+var DefaultLimits = Limits{Steps: 100000, Depth: 500}
+
+// WithLimits returns a context which causes Eval to fail once the
+// provided limits are exceeded.  All evaluations using the returned
+// context share the budget, so a new context should be created for
+// every evaluation.  The context must not be used concurrently.
+//
+// Eval always honors the cancellation of the context, with or
+// without limits.
+func WithLimits(ctx context.Context, limits Limits) context.Context {
+	return context.WithValue(ctx, budgetKey{}, &budget{limits: limits})
+}
+
+type budgetKey struct{}
+
+// budget tracks the resources used by an evaluation
+type budget struct {
+	limits       Limits
+	steps, depth int
+}
+
+// enter is called at the start of each Eval.  It returns an error if
+// the budget is exhausted.  Otherwise, exit must be called when the
+// evaluation is done.
+func (b *budget) enter() Value {
+	if b.limits.Steps > 0 && b.steps >= b.limits.Steps {
+		return newError("step limit exceeded")
+	}
+	if b.limits.Depth > 0 && b.depth >= b.limits.Depth {
+		return newError("depth limit exceeded")
+	}
+	b.steps++
+	b.depth++
+	return nil
+}
+
+func (b *budget) exit() {
+	b.depth--
+}
+
+// cancelled returns an error value if the context is done
+func cancelled(ctx context.Context) Value {
+	done := ctx.Done()
+	if done == nil {
+		return nil
+	}
+	select {
+	case <-done:
+		return newError("evaluation cancelled: " + ctx.Err().Error())
+	default:
+		return nil
+	}
+}
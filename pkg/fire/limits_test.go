@@ -0,0 +1,69 @@
+package fire_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestLimits(t *testing.T) {
+	suite := map[string]struct {
+		code     string
+		limits   fire.Limits
+		expected fire.Value
+	}{
+		"recursion": {
+			code:     `if(true, f(1), 2, where(f = {f(it)}))`,
+			limits:   fire.DefaultLimits,
+			expected: fire.Error("depth limit exceeded"),
+		},
+		"steps": {
+			code:     `1 + 2 + 3 + 4`,
+			limits:   fire.Limits{Steps: 5},
+			expected: fire.Error("step limit exceeded"),
+		},
+		"depth": {
+			code:     `1 + (2 + (3 + 4))`,
+			limits:   fire.Limits{Depth: 3},
+			expected: fire.Error("depth limit exceeded"),
+		},
+		"within": {
+			code:     `1 + (2 + (3 + 4))`,
+			limits:   fire.Limits{Steps: 7, Depth: 4},
+			expected: fire.Number(10),
+		},
+	}
+
+	for name, test := range suite {
+		t.Run(name, func(t *testing.T) {
+			parsed, errs := parse.String(test.code)
+			if len(errs) > 0 {
+				t.Fatal("Unexpected parse error", errs)
+			}
+			ctx := fire.WithLimits(context.Background(), test.limits)
+			got := fire.Eval(ctx, parsed, fire.Globals())
+			if !got.Equals(ctx, test.expected) {
+				t.Fatal("Unexpected result", got)
+			}
+		})
+	}
+}
+
+func TestCancel(t *testing.T) {
+	parsed, errs := parse.String(`if(true, f(1), 2, where(f = {if(f(it), 1, f(it))}))`)
+	if len(errs) > 0 {
+		t.Fatal("Unexpected parse error", errs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	ctx = fire.WithLimits(ctx, fire.Limits{Depth: 50})
+	got := fire.Eval(ctx, parsed, fire.Globals())
+	expected := fire.Error("evaluation cancelled: context deadline exceeded")
+	if !got.Equals(ctx, expected) {
+		t.Fatal("Unexpected result", got)
+	}
+}
@@ -0,0 +1,81 @@
+package fire_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestList(t *testing.T) {
+	ctx := context.Background()
+	items := []map[string]interface{}{
+		{"name": "boo", "age": 42},
+		{"name": "hoo", "age": 7},
+	}
+	scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("items"), fire.FromNative(ctx, items)})
+	l := func(values ...fire.Value) fire.Value {
+		return fire.List(values...)
+	}
+	n, s, b := fire.Number, fire.String, fire.Bool
+
+	suite := map[string]fire.Value{
+		`list()`:                                          l(),
+		`list(1, "a", x, where(x = 2))`:                   l(n(1), s("a"), n(2)),
+		`list(1, 2).(1)`:                                  n(2),
+		`list(1, 2).(2)`:                                  fire.Error("field not found: 2"),
+		`list(1, 2).length`:                               n(2),
+		`list(1, 2) == list(1, 2)`:                        b(true),
+		`list(1, 2) == list(2, 1)`:                        b(false),
+		`list(1, 2) == list(1)`:                           b(false),
+		`list(1, 2).map({it * 2})`:                        l(n(2), n(4)),
+		`list(1, 2, 3).filter(by = {it > 1})`:             l(n(2), n(3)),
+		`list(1, 2).filter({it})`:                         fire.Error("by must return a boolean"),
+		`list(1, 2).any({it > 1})`:                        b(true),
+		`list(1, 2).all({it > 1})`:                        b(false),
+		`list().all({it > 1})`:                            b(true),
+		`list(1, 2, 3).find({it > 1})`:                    n(2),
+		`list(1, 2, 3).find({it > 5})`:                    fire.Error("not found"),
+		`list(1, 2).contains(2)`:                          b(true),
+		`list(1, 2).contains(3)`:                          b(false),
+		`list(1, 2).contains(it = 2)`:                     b(true),
+		`list(object(it = 1)).contains(object(it = 1))`:   b(true),
+		`list(object(it = 1)).contains(1)`:                b(false),
+		`list(object()).contains(object())`:               b(true),
+		`list(object(by = 1)).map(object(by = 1)).length`: fire.Error("cannot call an object"),
+		`list(3, 1, 2).sort()`:                            l(n(1), n(2), n(3)),
+		`list("b", "a").sort()`:                           l(s("a"), s("b")),
+		`list(3, 1, 2).sort(by = {0 - it})`:               l(n(3), n(2), n(1)),
+		`list("a", 1, true).sort()`:                       l(b(true), n(1), s("a")),
+		`list("a", "b").join(", ")`:                       s("a, b"),
+		`list("a", "b").join()`:                           s("ab"),
+		`list("a", 1).join()`:                             fire.Error("join requires a list of strings"),
+		`list(1, 2).map({it.x})`:                          fire.Error("cannot lookup a number"),
+		`items.length`:                                    n(2),
+		`items.(1).name`:                                  s("hoo"),
+		`items.filter({it.age > 10}).map({it.name})`:      l(s("boo")),
+		`items.sort(by = {it.age}).(0).name`:              s("hoo"),
+		`items.map({it.name}) == list("boo", "hoo")`:      b(true),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, scope); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+
+	if code := l(n(1), s("a")).Code(ctx); code != `list(1, "a")` {
+		t.Error("Unexpected code", code)
+	}
+
+	native := fire.ToNative(ctx, l(n(1), s("a")))
+	if !reflect.DeepEqual(native, []interface{}{1.0, "a"}) {
+		t.Error("Unexpected native", native)
+	}
+}
@@ -0,0 +1,269 @@
+package fire
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// List creates a list value
+func List(values ...Value) Value {
+	return listValue(values)
+}
+
+type listValue []Value
+
+func (l listValue) Code(ctx context.Context) string {
+	elts := make([]string, len(l))
+	for kk, v := range l {
+		elts[kk] = v.Code(ctx)
+	}
+	return "list(" + strings.Join(elts, ", ") + ")"
+}
+
+func (l listValue) HashCode() interface{} {
+	return "(list)"
+}
+
+func (l listValue) Call(ctx context.Context, args ...Value) Value {
+	return newError("cannot call a list")
+}
+
+// Lookup supports indexing with numbers, the `length` property and
+// the list methods
+func (l listValue) Lookup(ctx context.Context, field Value) Value {
+	if f, ok := field.Number(ctx); ok {
+		idx := int(f)
+		if float64(idx) == f && idx >= 0 && idx < len(l) {
+			return l[idx]
+		}
+		return newError("field not found: " + field.Code(ctx))
+	}
+
+	s, _ := field.String(ctx)
+	switch s {
+	case "length":
+		return numberValue(len(l))
+	case "map":
+		return method(l, s, l.mapf)
+	case "filter":
+		return method(l, s, l.filter)
+	case "any":
+		return method(l, s, l.any)
+	case "all":
+		return method(l, s, l.all)
+	case "find":
+		return method(l, s, l.find)
+	case "contains":
+		return method(l, s, l.contains)
+	case "sort":
+		return method(l, s, l.sort)
+	case "join":
+		return method(l, s, l.join)
+	}
+	return newError("field not found: " + field.Code(ctx))
+}
+
+func (l listValue) Equals(ctx context.Context, other Value) bool {
+	o, ok := asList(other)
+	if !ok || len(o) != len(l) {
+		return false
+	}
+	for kk := range l {
+		if !l[kk].Equals(ctx, o[kk]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (l listValue) Number(ctx context.Context) (float64, bool) {
+	return 0, false
+}
+
+func (l listValue) String(ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (l listValue) Bool(ctx context.Context) (bool, bool) {
+	return false, false
+}
+
+func (l listValue) Error(ctx context.Context) (error, bool) {
+	return nil, false
+}
+
+// asList converts lists and native slices into a listValue
+func asList(v Value) (listValue, bool) {
+	switch v := v.(type) {
+	case listValue:
+		return v, true
+	case nativeValue:
+		return v.list()
+	}
+	return nil, false
+}
+
+func listf(ctx context.Context, args []interface{}, scope Value) Value {
+	result := make(listValue, len(args))
+	for kk, arg := range args {
+		if assignPattern.Match(arg) == nil {
+			return newError("list does not take named args")
+		}
+		result[kk] = Eval(ctx, arg, scope)
+	}
+	return result
+}
+
+// each calls the `by` arg for every element, stopping if the
+// callback returns false.  Errors from the callback are returned.
+func (l listValue) each(ctx context.Context, args []Value, fn func(elt, result Value) bool) Value {
+	by, ok := methodArg(args, "by")
+	if !ok {
+		return newError("missing by")
+	}
+	for _, elt := range l {
+		result := by.Call(ctx, elt)
+		if _, ok := result.Error(ctx); ok {
+			return result
+		}
+		if !fn(elt, result) {
+			break
+		}
+	}
+	return nil
+}
+
+// predicate is like each but requires the callback to return a bool
+func (l listValue) predicate(ctx context.Context, args []Value, fn func(elt Value, matched bool) bool) Value {
+	var err Value
+	errv := l.each(ctx, args, func(elt, result Value) bool {
+		b, ok := result.Bool(ctx)
+		if !ok {
+			err = newError("by must return a boolean")
+			return false
+		}
+		return fn(elt, b)
+	})
+	return checkError(errv, err)
+}
+
+func (l listValue) mapf(ctx context.Context, args ...Value) Value {
+	result := listValue{}
+	err := l.each(ctx, args, func(elt, v Value) bool {
+		result = append(result, v)
+		return true
+	})
+	return checkError(result, err)
+}
+
+func (l listValue) filter(ctx context.Context, args ...Value) Value {
+	result := listValue{}
+	err := l.predicate(ctx, args, func(elt Value, matched bool) bool {
+		if matched {
+			result = append(result, elt)
+		}
+		return true
+	})
+	return checkError(result, err)
+}
+
+func (l listValue) any(ctx context.Context, args ...Value) Value {
+	found := false
+	err := l.predicate(ctx, args, func(elt Value, matched bool) bool {
+		found = matched
+		return !matched
+	})
+	return checkError(boolValue(found), err)
+}
+
+func (l listValue) all(ctx context.Context, args ...Value) Value {
+	all := true
+	err := l.predicate(ctx, args, func(elt Value, matched bool) bool {
+		all = matched
+		return matched
+	})
+	return checkError(boolValue(all), err)
+}
+
+func (l listValue) find(ctx context.Context, args ...Value) Value {
+	var found Value = newError("not found")
+	err := l.predicate(ctx, args, func(elt Value, matched bool) bool {
+		if matched {
+			found = elt
+		}
+		return !matched
+	})
+	return checkError(found, err)
+}
+
+func (l listValue) contains(ctx context.Context, args ...Value) Value {
+	v, ok := methodArg(args, "it")
+	if !ok {
+		return newError("missing value")
+	}
+	for _, elt := range l {
+		if elt.Equals(ctx, v) {
+			return boolValue(true)
+		}
+	}
+	return boolValue(false)
+}
+
+// sort sorts the list using the same ordering as the comparison
+// operators.  The optional `by` arg maps each element to the key
+// used for sorting.
+func (l listValue) sort(ctx context.Context, args ...Value) Value {
+	keys := l
+	if _, ok := methodArg(args, "by"); ok {
+		mapped := l.mapf(ctx, args...)
+		if _, ok := mapped.Error(ctx); ok {
+			return mapped
+		}
+		keys = mapped.(listValue)
+	}
+
+	indices := make([]int, len(l))
+	for kk := range indices {
+		indices[kk] = kk
+	}
+	var err Value
+	sort.SliceStable(indices, func(i, j int) bool {
+		c, errv := compare(ctx, keys[indices[i]], keys[indices[j]])
+		if errv != nil {
+			err = errv
+		}
+		return c < 0
+	})
+	if err != nil {
+		return err
+	}
+
+	result := make(listValue, len(l))
+	for kk, idx := range indices {
+		result[kk] = l[idx]
+	}
+	return result
+}
+
+// join concatenates a list of strings with the optional separator
+func (l listValue) join(ctx context.Context, args ...Value) Value {
+	sep := ""
+	if v, ok := methodArg(args, "separator"); ok {
+		s, ok := v.String(ctx)
+		if !ok {
+			return newError("separator must be a string")
+		}
+		sep = s
+	}
+
+	elts := make([]string, len(l))
+	for kk, elt := range l {
+		s, ok := elt.String(ctx)
+		if !ok {
+			return newError("join requires a list of strings")
+		}
+		elts[kk] = s
+	}
+	return stringValue(strings.Join(elts, sep))
+}
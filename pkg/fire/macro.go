@@ -0,0 +1,364 @@
+package fire
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rameshvk/fig/pkg/match"
+)
+
+// Expand expands the macros defined in where clauses.  It is meant
+// to be called once on the result of parse.String, before Eval.
+//
+// A macro is defined by binding a name to `macro(body)`:
+//
+//      someFn(note("a comment"), 42, where(note = macro(where())))
+//
+// Every use of the name within the call (`note` or `note(...)`) is
+// replaced by the body.  If the body is a closure, it is called at
+// expansion time with the list expression of the arg (or a list of
+// them, if there are many) and must return the list expression to
+// use in its place:
+//
+//      xml(a + 1, where(xml = macro({transform(it)})))
+//
+// Names in the body that refer to other names bound in the same
+// where clause are resolved there, even if the macro is used where
+// those names are shadowed.
+//
+// The scope is used to evaluate closure macros.  Errors refer to the
+// location of the macro call.
+func Expand(ctx context.Context, v interface{}, scope Value) (interface{}, error) {
+	e := &expander{ctx: ctx}
+	result := e.expand(v, macros{}, scope, 0)
+	if e.err != nil {
+		return nil, e.err
+	}
+	return result, nil
+}
+
+// maxMacroDepth limits the nesting of closure macros whose results
+// use macros
+const maxMacroDepth = 100
+
+// macroDef is a single macro definition.  Closure macros have fn
+// set.
+type macroDef struct {
+	body interface{}
+	fn   Value
+}
+
+// macros maps names to the macros in scope.  A nil entry means the
+// name is bound to a regular value which shadows any outer macro.
+type macros map[string]*macroDef
+
+func (m macros) with(defs macros) macros {
+	if len(defs) == 0 {
+		return m
+	}
+	result := macros{}
+	for k, v := range m {
+		result[k] = v
+	}
+	for k, v := range defs {
+		if v == nil {
+			delete(result, k)
+		} else {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+type expander struct {
+	ctx     context.Context
+	err     *EvalError
+	gensyms int
+}
+
+func (e *expander) fail(loc Location, msg string) interface{} {
+	if e.err == nil {
+		e.err = &EvalError{Message: msg, Location: &loc}
+	}
+	return []interface{}{"error", msg}
+}
+
+func (e *expander) expand(v interface{}, env macros, scope Value, depth int) interface{} {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 || e.err != nil {
+		return v
+	}
+	head, _ := list[0].(string)
+	parts := strings.Split(head, ":")
+
+	switch parts[0] {
+	case "string", "number", "bool":
+		return v
+	case "name":
+		name, _ := list[1].(string)
+		if m := env[name]; m != nil {
+			if m.fn != nil {
+				return e.fail(nodeLocation(parts), "macro must be called: "+name)
+			}
+			return relocate(m.body, nodeLocation(parts), true)
+		}
+		return v
+	case "call":
+		var name string
+		if macroCallPattern(&name).Match(v) == nil && env[name] != nil {
+			return e.expandCall(env[name], name, list[2:], nodeLocation(parts), env, scope, depth)
+		}
+		fn := e.expand(list[1], env, scope, depth)
+		args, inner, innerScope := e.where(list[2:], env, scope, depth)
+		return append([]interface{}{head, fn}, e.expandAll(args, inner, innerScope, depth)...)
+	case "{}":
+		args, inner, innerScope := e.where(list[1:], env, scope, depth)
+		return append([]interface{}{head}, e.expandAll(args, inner, innerScope, depth)...)
+	}
+	return append([]interface{}{head}, e.expandAll(list[1:], env, scope, depth)...)
+}
+
+func (e *expander) expandAll(args []interface{}, env macros, scope Value, depth int) []interface{} {
+	result := make([]interface{}, len(args))
+	for kk, arg := range args {
+		result[kk] = e.expand(arg, env, scope, depth)
+	}
+	return result
+}
+
+// expandCall replaces a call to a macro
+func (e *expander) expandCall(m *macroDef, name string, args []interface{}, loc Location, env macros, scope Value, depth int) interface{} {
+	if m.fn == nil {
+		return relocate(m.body, loc, true)
+	}
+	if depth >= maxMacroDepth {
+		return e.fail(loc, "macro expansion too deep: "+name)
+	}
+
+	var arg interface{} = args
+	if len(args) == 1 {
+		arg = args[0]
+	}
+	result := m.fn.Call(e.ctx, FromNative(e.ctx, arg))
+	if err, ok := result.Error(e.ctx); ok {
+		return e.fail(loc, "macro "+name+": "+errorMessage(err))
+	}
+	expr := ToNative(e.ctx, result)
+	if !isExpression(expr) {
+		return e.fail(loc, "macro "+name+": invalid expression "+result.Code(e.ctx))
+	}
+	return e.expand(relocate(expr, loc, false), env, scope, depth+1)
+}
+
+// where processes the where clauses in args.  Macro definitions are
+// removed and the macros in scope for the rest of the args are
+// returned along with the scope for evaluating closure macros.
+func (e *expander) where(args []interface{}, env macros, scope Value, depth int) ([]interface{}, macros, Value) {
+	defs := macros{}
+	regular := map[string]bool{}
+	for _, arg := range args {
+		wargs, _ := whereArgs(arg)
+		for _, warg := range wargs {
+			if name, value, ok := whereBinding(warg); ok {
+				var body interface{}
+				regular[name] = macroDefPattern(&body).Match(value) != nil
+				defs[name] = nil
+			}
+		}
+	}
+	if len(defs) == 0 {
+		return args, env, scope
+	}
+
+	inner := newScope(scope)
+	result := make([]interface{}, 0, len(args))
+	for _, arg := range args {
+		wargs, ok := whereArgs(arg)
+		if !ok {
+			result = append(result, arg)
+			continue
+		}
+
+		clause := []interface{}{arg.([]interface{})[0], arg.([]interface{})[1]}
+		for _, warg := range wargs {
+			var body interface{}
+			name, value, ok := whereBinding(warg)
+			if !ok || macroDefPattern(&body).Match(value) != nil {
+				clause = append(clause, warg)
+				if ok {
+					inner.add(e.ctx, stringValue(name), value)
+				}
+				continue
+			}
+
+			body = e.expand(body, env, scope, depth)
+			if !isClosureExpression(body) {
+				body = e.hygienic(body, regular, &clause)
+				defs[name] = &macroDef{body: body}
+				continue
+			}
+			fn := Eval(e.ctx, body, inner)
+			if err, ok := fn.Error(e.ctx); ok {
+				e.fail(nodeLocation(strings.Split(headOf(warg), ":")), "macro "+name+": "+errorMessage(err))
+			}
+			defs[name] = &macroDef{body: body, fn: fn}
+		}
+		result = append(result, clause)
+	}
+	return result, env.with(defs), inner
+}
+
+// hygienic renames the names in body which refer to names bound in
+// the where clause of the macro definition.  The new names are bound
+// to the original ones in that where clause, so the references are
+// not affected by shadowing at the place where the macro is used.
+func (e *expander) hygienic(body interface{}, bindings map[string]bool, clause *[]interface{}) interface{} {
+	renamed := map[string]string{}
+	var rename func(v interface{}) interface{}
+	rename = func(v interface{}) interface{} {
+		list, ok := v.([]interface{})
+		if !ok || len(list) == 0 {
+			return v
+		}
+		head, _ := list[0].(string)
+		if strings.HasPrefix(head, "name") {
+			name, _ := list[1].(string)
+			if !bindings[name] {
+				return v
+			}
+			if _, ok := renamed[name]; !ok {
+				e.gensyms++
+				renamed[name] = fmt.Sprintf("%s (macro %d)", name, e.gensyms)
+				*clause = append(*clause, []interface{}{"=", []interface{}{"string", renamed[name]}, v})
+			}
+			return []interface{}{head, renamed[name]}
+		}
+		result := make([]interface{}, len(list))
+		result[0] = head
+		for kk, arg := range list[1:] {
+			result[kk+1] = rename(arg)
+		}
+		return result
+	}
+	return rename(body)
+}
+
+// whereBinding matches `name = value` in a where clause.  Chained
+// assignments (`x = y = 5`) are not matched.
+func whereBinding(warg interface{}) (string, interface{}, bool) {
+	var name string
+	var value interface{}
+	pattern := match.Pattern([]interface{}{
+		match.StringPrefix("="),
+		[]interface{}{match.StringPrefix("string"), &name},
+		&value,
+	})
+	if pattern.Match(warg) != nil || assignPattern.Match(value) == nil {
+		return "", nil, false
+	}
+	return name, value, true
+}
+
+// errorMessage returns the message of an error without the location
+func errorMessage(err error) string {
+	if e, ok := err.(*EvalError); ok {
+		return e.Message
+	}
+	return err.Error()
+}
+
+func headOf(v interface{}) string {
+	if list, ok := v.([]interface{}); ok && len(list) > 0 {
+		head, _ := list[0].(string)
+		return head
+	}
+	return ""
+}
+
+// macroDefPattern matches `macro(body)`
+func macroDefPattern(body *interface{}) match.Matcher {
+	return match.Pattern([]interface{}{
+		match.StringPrefix("call"),
+		[]interface{}{match.StringPrefix("name"), "macro"},
+		body,
+	})
+}
+
+// macroCallPattern matches calls of the form `name(...)`
+func macroCallPattern(name *string) match.Matcher {
+	return match.ListFirst(
+		match.StringPrefix("call"),
+		match.ListFirst([]interface{}{match.StringPrefix("name"), name}, match.Any()),
+	)
+}
+
+func isClosureExpression(v interface{}) bool {
+	return headOf(v) == "{}" || strings.HasPrefix(headOf(v), "{}:")
+}
+
+// isExpression checks if v is a valid list expression
+func isExpression(v interface{}) bool {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return false
+	}
+	head, ok := list[0].(string)
+	if !ok || head == "" {
+		return false
+	}
+
+	switch strings.Split(head, ":")[0] {
+	case "string", "name":
+		_, ok := list[1].(string)
+		return len(list) == 2 && ok
+	case "number":
+		switch list[1].(type) {
+		case int, float64:
+			return len(list) == 2
+		}
+		return false
+	case "bool":
+		_, ok := list[1].(bool)
+		return len(list) == 2 && ok
+	}
+	for _, arg := range list[1:] {
+		if !isExpression(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// relocate sets the location of all the nodes in v.  If force is not
+// set, only nodes without a location are updated.
+func relocate(v interface{}, loc Location, force bool) interface{} {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return v
+	}
+	head, _ := list[0].(string)
+	parts := strings.Split(head, ":")
+	if force || len(parts) != 3 {
+		head = fmt.Sprintf("%s:%d:%d", parts[0], loc.Start, loc.End)
+	}
+	result := make([]interface{}, len(list))
+	result[0] = head
+	for kk, arg := range list[1:] {
+		result[kk+1] = relocate(arg, loc, force)
+	}
+	return result
+}
+
+// nodeLocation is like location but allows nodes without locations
+func nodeLocation(parts []string) Location {
+	if len(parts) != 3 {
+		return Location{}
+	}
+	return location(parts)
+}
+
+// macrof is the value of macro when it was not removed by Expand
+func macrof(ctx context.Context, args []interface{}, scope Value) Value {
+	return newError("macro can only be used in where clauses")
+}
@@ -0,0 +1,119 @@
+package fire_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestMacros(t *testing.T) {
+	ctx := context.Background()
+	x := fire.List(fire.Number(10), fire.Number(20), fire.Number(30), fire.Number(40), fire.Number(50), fire.Number(60))
+	scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("x"), x})
+	eval := func(code string) fire.Value {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		expanded, err := fire.Expand(ctx, parsed, scope)
+		if err != nil {
+			return fire.Error(err.(*fire.EvalError).Message)
+		}
+		return fire.Eval(ctx, expanded, scope)
+	}
+
+	suite := map[string]fire.Value{
+		`list(x^5 + 1, where(x^5 = macro(x.(5))))`: fire.List(fire.Number(61)),
+
+		// note() is removed along with the where clause it expands to
+		`list(note("a comment"), 42, where(note = macro(where())))`: fire.List(fire.Number(42)),
+
+		// closure macros transform the AST of their args
+		`list(twice(1 + 2), where(twice = macro({list("+", it, it)})))`:       fire.List(fire.Number(6)),
+		`list(swap(10, 2), where(swap = macro({list("-", it.(1), it.(0))})))`: fire.List(fire.Number(-8)),
+
+		// closure macros can use regular bindings in the same clause
+		`list(neg(5), where(minus = "-", neg = macro({list(minus, list("number", 0), it)})))`: fire.List(fire.Number(-5)),
+
+		// macros are expanded in macro results and nested calls
+		`list(a(b(1)), where(b = macro({list("+", it, it)}), a = macro({list("*", it, it)})))`: fire.List(fire.Number(4)),
+
+		// hygiene: the body refers to the y in its own where clause
+		`list({y + five, where(y = 100)}(0), where(y = 5, five = macro(y)))`:   fire.List(fire.Number(105)),
+		`object(z = {five, where(y = 1)}(0), where(y = 5, five = macro(y))).z`: fire.Number(5),
+
+		// regular bindings shadow outer macros
+		`list(m, list(m, where(m = 2)), where(m = macro(1)))`: fire.List(fire.Number(1), fire.List(fire.Number(2))),
+
+		// errors
+		`macro(1)`:                        fire.Error("macro can only be used in where clauses"),
+		`list(f, where(f = macro({it})))`: fire.Error("macro must be called: f"),
+		`list(f(1), where(f = macro({error("boo")})))`:                        fire.Error("macro f: boo"),
+		`list(f(1), where(f = macro({list(1, 2)})))`:                          fire.Error("macro f: invalid expression list(1, 2)"),
+		`list(f(1), where(f = macro({list("call", list("name", "f"), it)})))`: fire.Error("macro expansion too deep: f"),
+	}
+
+	for code, expected := range suite {
+		if got := eval(code); !got.Equals(ctx, expected) {
+			t.Error("Unexpected", code, got.Code(ctx), expected.Code(ctx))
+		}
+	}
+}
+
+// plainError is a custom value whose error is not an EvalError
+type plainError struct {
+	fire.Value
+}
+
+func (p plainError) Error(ctx context.Context) (error, bool) {
+	return errors.New("boom"), true
+}
+
+func TestMacroCustomError(t *testing.T) {
+	ctx := context.Background()
+	boom := plainError{fire.Number(0)}
+	closures := fire.NativeFunction(func(ctx context.Context) string { return "{}" }, func(ctx context.Context, args []interface{}, scope fire.Value) fire.Value {
+		return boom
+	})
+	suite := map[string]fire.Value{
+		`list(f(1), where(f = macro({boom})))`: fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("boom"), boom}),
+		`list(f(1), where(f = macro({it})))`:   fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("{}"), closures}),
+	}
+	for code, scope := range suite {
+		parsed, _ := parse.String(code)
+		_, err := fire.Expand(ctx, parsed, scope)
+		if err == nil || err.(*fire.EvalError).Message != "macro f: boom" {
+			t.Error("Unexpected error", code, err)
+		}
+	}
+}
+
+func TestMacroErrorLocation(t *testing.T) {
+	ctx := context.Background()
+	code := `list(1, f(2), where(f = macro({3})))`
+	parsed, _ := parse.String(code)
+	_, err := fire.Expand(ctx, parsed, fire.Globals())
+	if err == nil {
+		t.Fatal("Expected error")
+	}
+	loc := err.(*fire.EvalError).Location
+	if loc == nil || code[loc.Start-1:loc.Start+1] != "f(" {
+		t.Error("Unexpected location", err)
+	}
+
+	// errors evaluating the expansion point at the macro call
+	code = `{1 + f(2), where(f = macro(error("boo")))}(0)`
+	parsed, _ = parse.String(code)
+	expanded, err := fire.Expand(ctx, parsed, fire.Globals())
+	if err != nil {
+		t.Fatal("Unexpected error", err)
+	}
+	err, _ = fire.Eval(ctx, expanded, fire.Globals()).Error(ctx)
+	loc = err.(*fire.EvalError).Location
+	if loc == nil || code[loc.Start-1:loc.Start+1] != "f(" {
+		t.Error("Unexpected location", err)
+	}
+}
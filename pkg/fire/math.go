@@ -0,0 +1,104 @@
+package fire
+
+import (
+	"context"
+	"math"
+)
+
+// mathObject is the `math` global
+func mathObject() Value {
+	unary := func(name string, fn func(float64) float64) Value {
+		return numeric("math."+name, []string{"x"}, func(f []float64) Value {
+			return numberValue(fn(f[0]))
+		})
+	}
+
+	return Object(map[Value]Value{
+		String("Inf"):   Number(math.Inf(+1)),
+		String("NaN"):   Number(math.NaN()),
+		String("floor"): unary("floor", math.Floor),
+		String("ceil"):  unary("ceil", math.Ceil),
+		String("round"): unary("round", math.Round),
+		String("abs"):   unary("abs", math.Abs),
+		String("sqrt"):  unary("sqrt", math.Sqrt),
+		String("log"):   unary("log", math.Log),
+		String("min"):   numeric("math.min", nil, minf),
+		String("max"):   numeric("math.max", nil, maxf),
+		String("pow"): numeric("math.pow", []string{"x", "y"}, func(f []float64) Value {
+			return numberValue(math.Pow(f[0], f[1]))
+		}),
+		String("mod"): numeric("math.mod", []string{"x", "y"}, func(f []float64) Value {
+			return modf(f[0], f[1])
+		}),
+		String("div"): numeric("math.div", []string{"x", "y"}, func(f []float64) Value {
+			if f[1] == 0 {
+				return newError("division by zero")
+			}
+			return numberValue(math.Floor(f[0] / f[1]))
+		}),
+		String("clamp"): numeric("math.clamp", []string{"x", "min", "max"}, func(f []float64) Value {
+			if f[1] > f[2] {
+				return newError("math.clamp: min is larger than max")
+			}
+			return numberValue(math.Max(f[1], math.Min(f[0], f[2])))
+		}),
+	})
+}
+
+// numeric creates a builtin function which takes numbers.  Error
+// args are returned as is.  See builtinFn for the use of names.
+func numeric(code string, names []string, fn func(args []float64) Value) Value {
+	return builtinFn{code, names, func(ctx context.Context, args []Value) Value {
+		f := make([]float64, len(args))
+		for kk, arg := range args {
+			if _, ok := arg.Error(ctx); ok {
+				return arg
+			}
+			n, ok := arg.Number(ctx)
+			if !ok {
+				return newError(code + ": not a number")
+			}
+			f[kk] = n
+		}
+		return fn(f)
+	}}
+}
+
+func minf(args []float64) Value {
+	if len(args) == 0 {
+		return newError("math.min requires at least one arg")
+	}
+	result := args[0]
+	for _, f := range args[1:] {
+		result = math.Min(result, f)
+	}
+	return numberValue(result)
+}
+
+func maxf(args []float64) Value {
+	if len(args) == 0 {
+		return newError("math.max requires at least one arg")
+	}
+	result := args[0]
+	for _, f := range args[1:] {
+		result = math.Max(result, f)
+	}
+	return numberValue(result)
+}
+
+// modf is the floored modulus: the result has the same sign as the
+// divisor, so `math.mod(-1, 10)` is 9.
+func modf(x, y float64) Value {
+	if y == 0 {
+		return newError("division by zero")
+	}
+	return numberValue(x - y*math.Floor(x/y))
+}
+
+func mod(ctx context.Context, args ...Value) Value {
+	f1, f2, err := numericArgs(ctx, args)
+	if err != nil {
+		return err
+	}
+	return modf(f1, f2)
+}
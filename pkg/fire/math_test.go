@@ -0,0 +1,62 @@
+package fire_test
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestMath(t *testing.T) {
+	ctx := context.Background()
+	n := fire.Number
+
+	suite := map[string]fire.Value{
+		`math.floor(1.5)`:                       n(1),
+		`math.floor(x = -1.5)`:                  n(-2),
+		`math.ceil(1.2)`:                        n(2),
+		`math.round(2.5)`:                       n(3),
+		`math.abs(-2)`:                          n(2),
+		`math.sqrt(16)`:                         n(4),
+		`math.log(1)`:                           n(0),
+		`math.min(3, 1, 2)`:                     n(1),
+		`math.max(3, 1, 2)`:                     n(3),
+		`math.min()`:                            fire.Error("math.min requires at least one arg"),
+		`math.pow(2, 10)`:                       n(1024),
+		`math.pow(x = 2, y = 3)`:                n(8),
+		`math.pow(2)`:                           fire.Error("math.pow requires x, y"),
+		`math.pow(x = 2)`:                       fire.Error("math.pow: missing y"),
+		`math.pow(2, y = 3)`:                    fire.Error("math.pow: cannot mix named and unnamed args"),
+		`math.clamp(15, 0, 10)`:                 n(10),
+		`math.clamp(x = -5, min = 0, max = 10)`: n(0),
+		`math.clamp(5, 10, 0)`:                  fire.Error("math.clamp: min is larger than max"),
+		`math.mod(7, 3)`:                        n(1),
+		`math.div(7, 2)`:                        n(3),
+		`math.div(7, 0)`:                        fire.Error("division by zero"),
+		`math.floor("x")`:                       fire.Error("math.floor: not a number"),
+		`math.floor(error("boo"))`:              fire.Error("boo"),
+		`7 % 3`:                                 n(1),
+		`(0 - 1) % 10`:                          n(9),
+		`10 % 4 * 2`:                            n(4),
+		`1 % 0`:                                 fire.Error("division by zero"),
+		`list(1.5, -1.5).map(math.floor)`:       fire.List(n(1), n(-2)),
+		`math.max(list(1, 2).length, 5)`:        n(5),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+
+	parsed, _ := parse.String(`math.NaN`)
+	if f, _ := fire.Eval(ctx, parsed, fire.Globals()).Number(ctx); !math.IsNaN(f) {
+		t.Error("Unexpected NaN", f)
+	}
+}
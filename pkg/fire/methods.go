@@ -0,0 +1,64 @@
+package fire
+
+import (
+	"context"
+)
+
+// method binds a method implementation to the receiver.  The code of
+// the returned function is `receiver.name`.
+func method(receiver Value, name string, fn func(ctx context.Context, args ...Value) Value) Value {
+	code := func(ctx context.Context) string {
+		return receiver.Code(ctx) + "." + name
+	}
+	return methodFunction(code, fn)
+}
+
+// methodFunction is like Function but the implementation can tell
+// named args apart from a single object arg (see methodArg)
+func methodFunction(code func(ctx context.Context) string, fn func(ctx context.Context, args ...Value) Value) Value {
+	return methodFn{funcValue{code, fn}}
+}
+
+// methodFn is a function whose arg is wrapped in namedArgs when
+// the call uses names (see callValue)
+type methodFn struct {
+	funcValue
+}
+
+// namedArgs is the arg of a call of a methodFn which used names:
+// `l.sort(by = f)`.  Objects passed as the only arg are not wrapped:
+// `l.contains(object(by = 1))`.
+type namedArgs struct {
+	obj
+}
+
+// methodArg fetches an argument of a method call.  Methods accept
+// either a single unnamed arg, `l.filter({...})`, or a named one,
+// `l.filter(by = {...})`.  It returns false if the arg is missing.
+func methodArg(args []Value, name string) (Value, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	if named, ok := args[0].(namedArgs); ok {
+		v, ok := named.obj[stringValue(name)]
+		return v, ok
+	}
+	return args[0], true
+}
+
+// namedArg fetches an argument that can only be passed by name.  A
+// single unnamed arg is treated as being named `it` unless it is an
+// object, whose fields are then used as the named args.
+func namedArg(args []Value, name string) (Value, bool) {
+	if len(args) != 1 {
+		return nil, false
+	}
+	if named, ok := args[0].(namedArgs); ok {
+		args = []Value{named.obj}
+	}
+	if o, ok := args[0].(obj); ok {
+		v, ok := o[stringValue(name)]
+		return v, ok
+	}
+	return args[0], name == "it"
+}
@@ -44,7 +44,7 @@ func (f nativeFnValue) Call(ctx context.Context, args ...Value) Value {
 
 func (f nativeFnValue) Lookup(ctx context.Context, field Value) Value {
 	// TODO: add methods
-	return errorValue("cannot lookup a function")
+	return newError("cannot lookup a function")
 }
 
 func (f nativeFnValue) Equals(ctx context.Context, other Value) bool {
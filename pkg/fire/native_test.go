@@ -0,0 +1,174 @@
+package fire_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+type address struct {
+	City string `json:"city"`
+	Zip  int    `json:"zip,omitempty"`
+}
+
+type user struct {
+	Email   string `json:"email"`
+	Age     uint8
+	Secret  string `json:"-"`
+	private string
+	Joined  time.Time `json:"joined"`
+	Home    *address  `json:"home"`
+	Work    *address  `json:"work"`
+	Tags    []string  `json:"tags"`
+	Props   map[string]float32
+	*address
+}
+
+func TestNative(t *testing.T) {
+	ctx := context.Background()
+	joined := time.Date(2019, 10, 1, 0, 0, 0, 0, time.UTC)
+	u := user{
+		Email:   "boo@example.com",
+		Age:     42,
+		Secret:  "hidden",
+		private: "hidden",
+		Joined:  joined,
+		Home:    &address{City: "Boston", Zip: 2134},
+		Tags:    []string{"beta", "staff"},
+		Props:   map[string]float32{"score": 0.5},
+		address: &address{City: "Embedded"},
+	}
+	scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("it"), fire.FromNative(ctx, &u)})
+
+	suite := map[string]fire.Value{
+		`it.email`:           fire.String("boo@example.com"),
+		`it.Age + 1`:         fire.Number(43),
+		`it.joined`:          fire.Time(joined),
+		`it.joined.year`:     fire.Number(2019),
+		`it.home.city`:       fire.String("Boston"),
+		`it.home.zip`:        fire.Number(2134),
+		`it.tags.(1)`:        fire.String("staff"),
+		`it.Props.score`:     fire.Number(0.5),
+		`it.city`:            fire.String("Embedded"),
+		`it.Secret`:          fire.Error("field not found: \"Secret\""),
+		`it.private`:         fire.Error("field not found: \"private\""),
+		`it.work`:            fire.Error("nil value"),
+		`it.tags.(2)`:        fire.Error("field not found: 2"),
+		`it.Props.missing`:   fire.Error("field not found: \"missing\""),
+		`it.home == it.home`: fire.Bool(true),
+		`it.home == it.tags`: fire.Bool(false),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", errs)
+		}
+		if got := fire.Eval(ctx, parsed, scope); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+
+	code := fire.FromNative(ctx, address{City: "Boston"}).Code(ctx)
+	if code != `object(city = "Boston", zip = 0)` {
+		t.Error("Unexpected code", code)
+	}
+
+	code = fire.FromNative(ctx, []int{1, 2}).Code(ctx)
+	if code != `list(1, 2)` {
+		t.Error("Unexpected code", code)
+	}
+}
+
+type node struct {
+	Name string
+	*node
+	Next *node
+}
+
+type outer struct {
+	inner
+}
+
+type inner struct {
+	*outer
+	Value int
+}
+
+func TestNativeRecursiveTypes(t *testing.T) {
+	ctx := context.Background()
+	n := fire.FromNative(ctx, node{Name: "a", node: &node{Name: "b"}, Next: &node{Name: "c"}})
+	if v := n.Lookup(ctx, fire.String("Name")); !v.Equals(ctx, fire.String("a")) {
+		t.Error("Unexpected name", v)
+	}
+	if v := n.Lookup(ctx, fire.String("Next")).Lookup(ctx, fire.String("Name")); !v.Equals(ctx, fire.String("c")) {
+		t.Error("Unexpected next", v)
+	}
+
+	o := fire.FromNative(ctx, outer{inner{Value: 5}})
+	if v := o.Lookup(ctx, fire.String("Value")); !v.Equals(ctx, fire.Number(5)) {
+		t.Error("Unexpected value", v)
+	}
+}
+
+func TestNativeListCached(t *testing.T) {
+	ctx := context.Background()
+	large := fire.FromNative(ctx, make([]int, 1000))
+	length := fire.String("length")
+	if v := large.Lookup(ctx, length); !v.Equals(ctx, fire.Number(1000)) {
+		t.Fatal("Unexpected length", v)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		large.Lookup(ctx, length)
+	})
+	if allocs > 10 {
+		t.Error("The list was converted again", allocs)
+	}
+}
+
+func TestNativeScalars(t *testing.T) {
+	ctx := context.Background()
+	numbers := []interface{}{
+		int(5), int8(5), int16(5), int32(5), int64(5),
+		uint(5), uint8(5), uint16(5), uint32(5), uint64(5), float32(5),
+	}
+	for _, n := range numbers {
+		if v := fire.FromNative(ctx, n); !v.Equals(ctx, fire.Number(5)) {
+			t.Error("Unexpected number", n, v)
+		}
+	}
+
+	type named string
+	if v := fire.FromNative(ctx, named("x")); !v.Equals(ctx, fire.String("x")) {
+		t.Error("Unexpected string", v)
+	}
+
+	var nilp *address
+	if v := fire.FromNative(ctx, nilp); !v.Equals(ctx, fire.Error("nil value")) {
+		t.Error("Unexpected nil", v)
+	}
+
+	if v := fire.FromNative(ctx, map[int]int{}); !v.Equals(ctx, fire.Error("unknown native type")) {
+		t.Error("Unexpected map", v)
+	}
+
+	if v := fire.FromNative(ctx, fire.Number(2)); !v.Equals(ctx, fire.Number(2)) {
+		t.Error("Unexpected value", v)
+	}
+
+	values := []interface{}{
+		map[string]string{"user": "boo"},
+		[]string{"a", "b"},
+		address{City: "Boston"},
+	}
+	for _, value := range values {
+		if dupe := fire.ToNative(ctx, fire.FromNative(ctx, value)); !reflect.DeepEqual(value, dupe) {
+			t.Error("Mismatched", value, dupe)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package fire
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+// fromReflect converts an arbitrary Go value into a Value.
+//
+// Scalars are converted right away but structs, slices and maps are
+// wrapped lazily: their fields are only converted when looked up.
+func fromReflect(rv reflect.Value) Value {
+	if !rv.IsValid() {
+		return newError("nil value")
+	}
+
+	if rv.CanInterface() {
+		switch v := rv.Interface().(type) {
+		case time.Time:
+			return timeValue{v.UTC()}
+		case error:
+			if rv.Kind() != reflect.Ptr || !rv.IsNil() {
+				return newError(v.Error())
+			}
+		}
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return newError("nil value")
+		}
+		return fromReflect(rv.Elem())
+	case reflect.Bool:
+		return boolValue(rv.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return numberValue(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return numberValue(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return numberValue(rv.Float())
+	case reflect.String:
+		return stringValue(rv.String())
+	case reflect.Slice, reflect.Array:
+		return nativeValue{rv, &nativeList{}}
+	case reflect.Struct:
+		return nativeValue{rv, nil}
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			return nativeValue{rv, nil}
+		}
+	}
+	return newError("unknown native type")
+}
+
+// nativeValue wraps a Go struct, slice or string-keyed map without
+// copying it.  Slices behave like lists.
+type nativeValue struct {
+	rv reflect.Value
+
+	// elts is the list form of slices and arrays, which is only
+	// converted when a list method is used
+	elts *nativeList
+}
+
+type nativeList struct {
+	once sync.Once
+	list listValue
+}
+
+func (n nativeValue) Code(ctx context.Context) string {
+	if l, ok := n.list(); ok {
+		return l.Code(ctx)
+	}
+
+	names := n.names()
+	fields := make([]string, len(names))
+	for kk, name := range names {
+		fields[kk] = parse.Name(name) + " = " + n.Lookup(ctx, stringValue(name)).Code(ctx)
+	}
+	return "object(" + strings.Join(fields, ", ") + ")"
+}
+
+func (n nativeValue) HashCode() interface{} {
+	if l, ok := n.list(); ok {
+		return l.HashCode()
+	}
+	return "(native)"
+}
+
+func (n nativeValue) Call(ctx context.Context, args ...Value) Value {
+	return newError("cannot call an object")
+}
+
+func (n nativeValue) Lookup(ctx context.Context, field Value) Value {
+	switch n.rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if f, ok := field.Number(ctx); ok {
+			idx := int(f)
+			if float64(idx) == f && idx >= 0 && idx < n.rv.Len() {
+				return fromReflect(n.rv.Index(idx))
+			}
+		} else {
+			l, _ := n.list()
+			return l.Lookup(ctx, field)
+		}
+	case reflect.Map:
+		if s, ok := field.String(ctx); ok {
+			key := reflect.ValueOf(s).Convert(n.rv.Type().Key())
+			if v := n.rv.MapIndex(key); v.IsValid() {
+				return fromReflect(v)
+			}
+		}
+	case reflect.Struct:
+		if s, ok := field.String(ctx); ok {
+			if index, ok := structFields(n.rv.Type())[s]; ok {
+				return fieldByIndex(n.rv, index)
+			}
+		}
+	}
+	return newError("field not found: " + field.Code(ctx))
+}
+
+func (n nativeValue) Equals(ctx context.Context, other Value) bool {
+	if l, ok := n.list(); ok {
+		return l.Equals(ctx, other)
+	}
+	o, ok := other.(nativeValue)
+	if !ok || !n.rv.CanInterface() || !o.rv.CanInterface() {
+		return false
+	}
+	return reflect.DeepEqual(n.rv.Interface(), o.rv.Interface())
+}
+
+func (n nativeValue) Number(ctx context.Context) (float64, bool) {
+	return 0, false
+}
+
+func (n nativeValue) String(ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (n nativeValue) Bool(ctx context.Context) (bool, bool) {
+	return false, false
+}
+
+func (n nativeValue) Error(ctx context.Context) (error, bool) {
+	return nil, false
+}
+
+// list converts slices and arrays into a list of values.  The
+// conversion is done once per value.
+func (n nativeValue) list() (listValue, bool) {
+	if n.elts == nil {
+		return nil, false
+	}
+	n.elts.once.Do(func() {
+		n.elts.list = make(listValue, n.rv.Len())
+		for kk := range n.elts.list {
+			n.elts.list[kk] = fromReflect(n.rv.Index(kk))
+		}
+	})
+	return n.elts.list, true
+}
+
+// names returns the sorted field names of a struct or map
+func (n nativeValue) names() []string {
+	result := []string{}
+	if n.rv.Kind() == reflect.Map {
+		for _, key := range n.rv.MapKeys() {
+			result = append(result, key.String())
+		}
+	} else {
+		for name := range structFields(n.rv.Type()) {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex except it does not
+// panic on nil embedded pointers
+func fieldByIndex(rv reflect.Value, index []int) Value {
+	for kk, idx := range index {
+		if kk > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return newError("nil value")
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(idx)
+	}
+	return fromReflect(rv)
+}
+
+// structFields maps the visible field names of a struct type to the
+// index sequence of the field.  Names follow the encoding/json
+// conventions: the json tag overrides the name, "-" hides the field
+// and fields of embedded structs are promoted.
+func structFields(t reflect.Type) map[string][]int {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	result := collectFields(t, map[reflect.Type]bool{})
+	fieldsCache.Store(t, result)
+	return result
+}
+
+// collectFields implements structFields.  Embedded structs which are
+// already being collected, such as in `type T struct{ *T }`, are
+// skipped.
+func collectFields(t reflect.Type, visiting map[reflect.Type]bool) map[string][]int {
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	result := map[string][]int{}
+	var embedded [][]int
+	for kk := 0; kk < t.NumField(); kk++ {
+		f := t.Field(kk)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+
+		ft := f.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if f.Anonymous && tag == "" && ft.Kind() == reflect.Struct {
+			embedded = append(embedded, f.Index)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		result[tag] = f.Index
+	}
+
+	// promoted fields do not override direct fields
+	for _, index := range embedded {
+		ft := t.FieldByIndex(index).Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if visiting[ft] {
+			continue
+		}
+		for name, inner := range collectFields(ft, visiting) {
+			if _, ok := result[name]; !ok {
+				result[name] = append(append([]int{}, index...), inner...)
+			}
+		}
+	}
+	return result
+}
+
+var fieldsCache sync.Map
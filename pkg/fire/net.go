@@ -0,0 +1,258 @@
+package fire
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+)
+
+// IP converts a Go IP address into an ip value
+func IP(ip net.IP) Value {
+	return ipValue{ip}
+}
+
+// ipValue is an IPv4 or IPv6 address
+type ipValue struct {
+	ip net.IP
+}
+
+func (i ipValue) Code(ctx context.Context) string {
+	return "net.ip(" + stringValue(i.ip.String()).Code(ctx) + ")"
+}
+
+func (i ipValue) HashCode() interface{} {
+	return "net.ip:" + i.ip.String()
+}
+
+func (i ipValue) Call(ctx context.Context, args ...Value) Value {
+	return newError("cannot call an ip")
+}
+
+// Lookup supports the `version` field (4 or 6) and the `inCIDR`
+// method
+func (i ipValue) Lookup(ctx context.Context, field Value) Value {
+	name, _ := field.String(ctx)
+	switch name {
+	case "version":
+		if i.ip.To4() != nil {
+			return numberValue(4)
+		}
+		return numberValue(6)
+	case "inCIDR":
+		return method(i, name, i.inCIDR)
+	}
+	return newError("field not found: " + field.Code(ctx))
+}
+
+func (i ipValue) Equals(ctx context.Context, other Value) bool {
+	o, ok := other.(ipValue)
+	return ok && o.ip.Equal(i.ip)
+}
+
+func (i ipValue) Number(ctx context.Context) (float64, bool) {
+	return 0, false
+}
+
+func (i ipValue) String(ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (i ipValue) Bool(ctx context.Context) (bool, bool) {
+	return false, false
+}
+
+func (i ipValue) Error(ctx context.Context) (error, bool) {
+	return nil, false
+}
+
+// inCIDR checks if the address is in a CIDR block.  The arg can be a
+// single block, a list of blocks or a set created by net.cidrs:
+//
+//      net.ip(ip).inCIDR(list("10.0.0.0/8", "192.168.0.0/16"))
+func (i ipValue) inCIDR(ctx context.Context, args ...Value) Value {
+	v, ok := methodArg(args, "cidr")
+	if !ok {
+		return newError("missing cidr")
+	}
+	set, ok := v.(cidrSet)
+	if !ok {
+		result := toCIDRs(ctx, "inCIDR", []Value{v})
+		if set, ok = result.(cidrSet); !ok {
+			return result
+		}
+	}
+	return boolValue(set.contains(i.ip))
+}
+
+// cidrSet is a set of parsed CIDR blocks
+type cidrSet struct {
+	blocks []string
+	nets   []*net.IPNet
+}
+
+func (c cidrSet) Code(ctx context.Context) string {
+	args := make([]string, len(c.blocks))
+	for kk, block := range c.blocks {
+		args[kk] = stringValue(block).Code(ctx)
+	}
+	return "net.cidrs(" + strings.Join(args, ", ") + ")"
+}
+
+func (c cidrSet) HashCode() interface{} {
+	return "net.cidrs:" + strings.Join(c.blocks, ",")
+}
+
+func (c cidrSet) Call(ctx context.Context, args ...Value) Value {
+	return newError("cannot call a cidr set")
+}
+
+// Lookup supports the `contains` method which accepts ip values as
+// well as strings
+func (c cidrSet) Lookup(ctx context.Context, field Value) Value {
+	name, _ := field.String(ctx)
+	if name == "contains" {
+		return method(c, name, func(ctx context.Context, args ...Value) Value {
+			v, ok := methodArg(args, "ip")
+			if !ok {
+				return newError("missing ip")
+			}
+			ip := toIP(ctx, v)
+			if i, ok := ip.(ipValue); ok {
+				return boolValue(c.contains(i.ip))
+			}
+			return ip
+		})
+	}
+	return newError("field not found: " + field.Code(ctx))
+}
+
+func (c cidrSet) Equals(ctx context.Context, other Value) bool {
+	o, ok := other.(cidrSet)
+	return ok && o.HashCode() == c.HashCode()
+}
+
+func (c cidrSet) Number(ctx context.Context) (float64, bool) {
+	return 0, false
+}
+
+func (c cidrSet) String(ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (c cidrSet) Bool(ctx context.Context) (bool, bool) {
+	return false, false
+}
+
+func (c cidrSet) Error(ctx context.Context) (error, bool) {
+	return nil, false
+}
+
+func (c cidrSet) contains(ip net.IP) bool {
+	for _, n := range c.nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// netObject is the `net` global
+func netObject() Value {
+	return Object(map[Value]Value{
+		String("ip"): builtinFn{"net.ip", []string{"it"}, func(ctx context.Context, args []Value) Value {
+			return toIP(ctx, args[0])
+		}},
+		String("cidrs"): builtinFn{"net.cidrs", nil, func(ctx context.Context, args []Value) Value {
+			return toCIDRs(ctx, "net.cidrs", args)
+		}},
+	})
+}
+
+// toIP converts ip values and strings into ip values
+func toIP(ctx context.Context, v Value) Value {
+	if _, ok := v.Error(ctx); ok {
+		return v
+	}
+	if i, ok := v.(ipValue); ok {
+		return i
+	}
+	s, ok := v.String(ctx)
+	if !ok {
+		return newError("not an ip")
+	}
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return newError("invalid ip " + v.Code(ctx))
+	}
+	return ipValue{ip}
+}
+
+// toCIDRs converts strings (or lists of strings) into a cidr set
+func toCIDRs(ctx context.Context, name string, args []Value) Value {
+	var result cidrSet
+	var add func(v Value) Value
+	add = func(v Value) Value {
+		if _, ok := v.Error(ctx); ok {
+			return v
+		}
+		if l, ok := asList(v); ok {
+			for _, elt := range l {
+				if err := add(elt); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if set, ok := v.(cidrSet); ok {
+			result.blocks = append(result.blocks, set.blocks...)
+			result.nets = append(result.nets, set.nets...)
+			return nil
+		}
+		s, ok := v.String(ctx)
+		if !ok {
+			return newError(name + ": cidr must be a string")
+		}
+		n, err := parseCIDR(s)
+		if err != nil {
+			return newError(name + ": invalid cidr " + v.Code(ctx))
+		}
+		result.blocks = append(result.blocks, s)
+		result.nets = append(result.nets, n)
+		return nil
+	}
+
+	for _, arg := range args {
+		if err := add(arg); err != nil {
+			return err
+		}
+	}
+	return result
+}
+
+// parseCIDR parses CIDR blocks, caching the results.  Like compile,
+// the cache is cleared when it gets too big.
+func parseCIDR(s string) (*net.IPNet, error) {
+	cidrs.Lock()
+	defer cidrs.Unlock()
+
+	if n, ok := cidrs.cache[s]; ok {
+		return n, nil
+	}
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(cidrs.cache) >= maxCIDRs {
+		cidrs.cache = map[string]*net.IPNet{}
+	}
+	cidrs.cache[s] = n
+	return n, nil
+}
+
+const maxCIDRs = 1000
+
+var cidrs = struct {
+	sync.Mutex
+	cache map[string]*net.IPNet
+}{cache: map[string]*net.IPNet{}}
@@ -0,0 +1,57 @@
+package fire_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestNet(t *testing.T) {
+	ctx := context.Background()
+	n, b := fire.Number, fire.Bool
+
+	suite := map[string]fire.Value{
+		`net.ip("10.1.2.3").inCIDR("10.0.0.0/8")`:                                 b(true),
+		`net.ip("11.1.2.3").inCIDR("10.0.0.0/8")`:                                 b(false),
+		`net.ip("::ffff:10.1.2.3").inCIDR("10.0.0.0/8")`:                          b(true),
+		`net.ip("2001:db8::1").inCIDR(cidr = "2001:db8::/32")`:                    b(true),
+		`net.ip("2001:db9::1").inCIDR("2001:db8::/32")`:                           b(false),
+		`net.ip("192.168.1.1").inCIDR(list("10.0.0.0/8", "192.168.0.0/16"))`:      b(true),
+		`net.ip("192.168.1.1").inCIDR(net.cidrs("10.0.0.0/8"))`:                   b(false),
+		`net.cidrs("10.0.0.0/8", list("192.168.0.0/16")).contains("192.168.1.1")`: b(true),
+		`net.cidrs("10.0.0.0/8").contains(net.ip("10.0.0.1"))`:                    b(true),
+		`net.ip("10.1.2.3").version`:                                              n(4),
+		`net.ip("::1").version`:                                                   n(6),
+		`net.ip("10.1.2.3") == net.ip("10.1.2.3")`:                                b(true),
+		`net.ip("10.1.2.3") == net.ip("::ffff:10.1.2.3")`:                         b(true),
+		`net.ip("10.1.2.3") == "10.1.2.3"`:                                        b(false),
+		`net.ip("10.1.2")`:                                                        fire.Error(`invalid ip "10.1.2"`),
+		`net.ip(5)`:                                                               fire.Error(`not an ip`),
+		`net.ip("10.1.2.3").inCIDR("10.0.0.0")`:                                   fire.Error(`inCIDR: invalid cidr "10.0.0.0"`),
+		`net.cidrs("10.0.0.0/8", 5)`:                                              fire.Error(`net.cidrs: cidr must be a string`),
+		`net.cidrs("10.0.0.0/8").contains("x")`:                                   fire.Error(`invalid ip "x"`),
+		`net.ip(error("boo"))`:                                                    fire.Error("boo"),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+
+	ip := fire.IP(net.ParseIP("10.1.2.3"))
+	if code := ip.Code(ctx); code != `net.ip("10.1.2.3")` {
+		t.Error("Unexpected code", code)
+	}
+	parsed, _ := parse.String(`net.cidrs("10.0.0.0/8", "::1/128")`)
+	if code := fire.Eval(ctx, parsed, fire.Globals()).Code(ctx); code != `net.cidrs("10.0.0.0/8", "::1/128")` {
+		t.Error("Unexpected code", code)
+	}
+}
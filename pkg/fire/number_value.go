@@ -31,12 +31,12 @@ func (n numberValue) HashCode() interface{} {
 }
 
 func (n numberValue) Call(ctx context.Context, args ...Value) Value {
-	return errorValue("cannot call a number")
+	return newError("cannot call a number")
 }
 
 func (n numberValue) Lookup(ctx context.Context, field Value) Value {
 	// TODO: add string methods
-	return errorValue("cannot lookup a number")
+	return newError("cannot lookup a number")
 }
 
 func (n numberValue) Equals(ctx context.Context, other Value) bool {
@@ -2,6 +2,12 @@ package fire
 
 import (
 	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/rameshvk/fig/pkg/parse"
 )
 
 // Object creates an object
@@ -11,27 +17,57 @@ func Object(v map[Value]Value) Value {
 
 type obj map[Value]Value
 
+// Code returns `object(key = value, ...)` with the keys sorted.  Keys
+// which are not valid names are quoted: `object("user-id" = 5)`
 func (o obj) Code(ctx context.Context) string {
-	panic("not yet implemented")
+	fields := make([]string, 0, len(o))
+	for k, v := range o {
+		name, ok := k.String(ctx)
+		if !ok {
+			name = k.Code(ctx)
+		}
+		fields = append(fields, parse.Name(name)+" = "+v.Code(ctx))
+	}
+	sort.Strings(fields)
+	return "object(" + strings.Join(fields, ", ") + ")"
 }
 
+// HashCode combines the hash codes of the fields in an order
+// independent way
 func (o obj) HashCode() interface{} {
-	return "(obj)"
+	var result objHash
+	for k, v := range o {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%#v=%#v", k.HashCode(), v.HashCode())
+		result += objHash(h.Sum64())
+	}
+	return result
 }
 
+type objHash uint64
+
 func (o obj) Call(ctx context.Context, args ...Value) Value {
-	return errorValue("cannot call an object")
+	return newError("cannot call an object")
 }
 
 func (o obj) Lookup(ctx context.Context, field Value) Value {
 	if v, ok := o[field]; ok {
 		return v
 	}
-	return errorValue("field not found: " + field.Code(ctx))
+	return newError("field not found: " + field.Code(ctx))
 }
 
 func (o obj) Equals(ctx context.Context, other Value) bool {
-	panic("not yet implemented")
+	x, ok := other.(obj)
+	if !ok || len(x) != len(o) {
+		return false
+	}
+	for k, v := range o {
+		if xv, ok := x[k]; !ok || !v.Equals(ctx, xv) {
+			return false
+		}
+	}
+	return true
 }
 
 func (o obj) Number(ctx context.Context) (float64, bool) {
@@ -49,3 +85,11 @@ func (o obj) Bool(ctx context.Context) (bool, bool) {
 func (o obj) Error(ctx context.Context) (error, bool) {
 	return nil, false
 }
+
+func (o obj) copy() obj {
+	result := make(obj, len(o))
+	for k, v := range o {
+		result[k] = v
+	}
+	return result
+}
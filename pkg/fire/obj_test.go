@@ -0,0 +1,106 @@
+package fire_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestObject(t *testing.T) {
+	ctx := context.Background()
+	eval := func(code string) fire.Value {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		return fire.Eval(ctx, parsed, fire.Globals())
+	}
+
+	x := eval(`object(x = 1, y = object(z = "a\"b"))`)
+	y := eval(`object(y = object(z = "a\"b"), x = 1)`)
+	if !x.Equals(ctx, y) || x.HashCode() != y.HashCode() {
+		t.Error("Objects differ", x, y)
+	}
+
+	for _, code := range []string{`object(x = 1)`, `object(x = 1, y = 2, z = 3)`, `object(x = 2, y = object(z = "a\"b"))`, `list(1)`} {
+		if x.Equals(ctx, eval(code)) {
+			t.Error("Unexpected equality", code)
+		}
+	}
+
+	if v := eval(`object(x = 1, y = 2) == object(y = 2, x = 1)`); !v.Equals(ctx, fire.Bool(true)) {
+		t.Error("Unexpected result", v)
+	}
+
+	code := x.Code(ctx)
+	if code != `object(x = 1, y = object(z = "a\"b"))` {
+		t.Error("Unexpected code", code)
+	}
+	if !eval(code).Equals(ctx, x) {
+		t.Error("Code did not round trip", code)
+	}
+
+	keys := fire.Object(map[fire.Value]fire.Value{
+		fire.String("user-id"): fire.Number(1),
+		fire.String("a b"):     fire.String("c"),
+		fire.String("true"):    fire.Bool(true),
+		fire.String(""):        fire.Number(2),
+		fire.String("x"):       fire.Object(map[fire.Value]fire.Value{fire.String(`a"b`): fire.Number(3)}),
+	})
+	code = keys.Code(ctx)
+	if code != `object("" = 2, "a b" = "c", "true" = true, "user-id" = 1, x = object("a\"b" = 3))` {
+		t.Error("Unexpected code", code)
+	}
+	if !eval(code).Equals(ctx, keys) {
+		t.Error("Code did not round trip", code)
+	}
+}
+
+func TestClosureCode(t *testing.T) {
+	ctx := context.Background()
+	eval := func(code string) fire.Value {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		return fire.Eval(ctx, parsed, fire.Globals())
+	}
+
+	c := eval(`{ it.x * (y + 1), y = 2 }`)
+	code := c.Code(ctx)
+	if code != `{it.x * (y + 1), y = 2}` {
+		t.Error("Unexpected code", code)
+	}
+	if v := eval(code + `(x = 3)`); !v.Equals(ctx, fire.Number(9)) {
+		t.Error("Code did not round trip", v)
+	}
+
+	if !c.Equals(ctx, eval(`{it.x * (y+1), y = 2}`)) || c.Equals(ctx, eval(`{it.x}`)) {
+		t.Error("Unexpected equality")
+	}
+	if v := eval(`{it} == {it}`); !v.Equals(ctx, fire.Bool(true)) {
+		t.Error("Unexpected result", v)
+	}
+
+	// captured names are compared by value
+	suite := map[string]bool{
+		`f(1) != f(2), where(f = {{it + y, y = it}})`:               true,
+		`f(1) != f(1), where(f = {{it + y, y = it}})`:               false,
+		`f(1) != g(1), where(f = {{it + it2}(0), it2 = it}, g = f)`: false,
+		`{it + y, y = 1} != {it + y, y = 2}`:                        true,
+		`{x + it} != {x + it}, where(x = 5)`:                        false,
+		`{f(it)} != {f(it)}, where(f = {f(it)})`:                    false,
+	}
+	for code, expected := range suite {
+		if v := eval(`list(` + code + `)`); !v.Equals(ctx, fire.List(fire.Bool(expected))) {
+			t.Error("Unexpected result", code, v.Code(ctx))
+		}
+	}
+
+	closure := fire.Closure([]interface{}{"name", "it"}, fire.Globals())
+	if code := closure.Code(ctx); code != `{it}` {
+		t.Error("Unexpected code", code)
+	}
+}
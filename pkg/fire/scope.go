@@ -16,7 +16,7 @@ func Scope(ctx context.Context, parent Value, pairs ...[2]Value) Value {
 }
 
 func newScope(parent Value) *scope {
-	err := errorValue("internal error")
+	err := newError("internal error")
 	return &scope{err, parent, map[interface{}][]*nameValue{}}
 }
 
@@ -35,7 +35,7 @@ func (s *scope) add(ctx context.Context, name Value, value interface{}) Value {
 	}
 	for _, entry := range s.pairs[hash] {
 		if entry.name.Equals(ctx, name) {
-			return errorValue("duplicate name: " + name.Code(ctx))
+			return newError("duplicate name: " + name.Code(ctx))
 		}
 	}
 	v, _ := value.(Value)
@@ -57,7 +57,7 @@ func (s *scope) Lookup(ctx context.Context, field Value) Value {
 		}
 
 		if entry.inProgress {
-			return errorValue("recursion detected: " + field.Code(ctx))
+			return newError("recursion detected: " + field.Code(ctx))
 		}
 		return entry.value
 	}
@@ -0,0 +1,409 @@
+package fire
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// semverObject is the `semver` global
+func semverObject() Value {
+	return Object(map[Value]Value{
+		String("parse"):     builtinFn{"semver.parse", []string{"it"}, semverParse},
+		String("valid"):     builtinFn{"semver.valid", []string{"it"}, semverValid},
+		String("compare"):   builtinFn{"semver.compare", []string{"x", "y"}, semverCompare},
+		String("satisfies"): builtinFn{"semver.satisfies", []string{"version", "range"}, semverSatisfies},
+	})
+}
+
+func semverParse(ctx context.Context, args []Value) Value {
+	v, err := semverArg(ctx, "semver.parse", args[0])
+	if err != nil {
+		return err
+	}
+	return Object(map[Value]Value{
+		String("major"):      numberValue(v.major),
+		String("minor"):      numberValue(v.minor),
+		String("patch"):      numberValue(v.patch),
+		String("prerelease"): stringValue(strings.Join(v.pre, ".")),
+		String("build"):      stringValue(v.build),
+	})
+}
+
+func semverValid(ctx context.Context, args []Value) Value {
+	s, ok := args[0].String(ctx)
+	if !ok {
+		return boolValue(false)
+	}
+	_, ok = parseVersion(s)
+	return boolValue(ok)
+}
+
+func semverCompare(ctx context.Context, args []Value) Value {
+	x, err := semverArg(ctx, "semver.compare", args[0])
+	if err != nil {
+		return err
+	}
+	y, err := semverArg(ctx, "semver.compare", args[1])
+	if err != nil {
+		return err
+	}
+	return numberValue(x.compare(y))
+}
+
+func semverSatisfies(ctx context.Context, args []Value) Value {
+	v, err := semverArg(ctx, "semver.satisfies", args[0])
+	if err != nil {
+		return err
+	}
+	if _, ok := args[1].Error(ctx); ok {
+		return args[1]
+	}
+	s, ok := args[1].String(ctx)
+	if !ok {
+		return newError("semver.satisfies: range is not a string")
+	}
+	r, ok := parseRange(s)
+	if !ok {
+		return newError("semver.satisfies: invalid range " + args[1].Code(ctx))
+	}
+	return boolValue(r.contains(v))
+}
+
+func semverArg(ctx context.Context, name string, arg Value) (version, Value) {
+	if _, ok := arg.Error(ctx); ok {
+		return version{}, arg
+	}
+	s, ok := arg.String(ctx)
+	if !ok {
+		return version{}, newError(name + ": not a string")
+	}
+	v, ok := parseVersion(s)
+	if !ok {
+		return version{}, newError(name + ": invalid version " + arg.Code(ctx))
+	}
+	return v, nil
+}
+
+// version is a semantic version (https://semver.org).  The build
+// metadata is ignored when comparing versions.
+type version struct {
+	major, minor, patch int
+	pre                 []string
+	build               string
+}
+
+// parseVersion parses MAJOR.MINOR.PATCH[-PRERELEASE][+BUILD] with an
+// optional leading "v"
+func parseVersion(s string) (version, bool) {
+	parts, v, ok := parsePartial(s)
+	return v, ok && parts == 3
+}
+
+// parsePartial parses versions where the minor and patch numbers
+// may be missing (or be wildcards: x, X or *).  It returns the
+// number of parts that were specified.
+func parsePartial(s string) (int, version, bool) {
+	var v version
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "="), "v")
+	if idx := strings.IndexByte(s, '+'); idx >= 0 {
+		s, v.build = s[:idx], s[idx+1:]
+		if !validIdentifiers(v.build, false) {
+			return 0, v, false
+		}
+	}
+	if idx := strings.IndexByte(s, '-'); idx >= 0 {
+		var pre string
+		s, pre = s[:idx], s[idx+1:]
+		if !validIdentifiers(pre, true) {
+			return 0, v, false
+		}
+		v.pre = strings.Split(pre, ".")
+	}
+
+	numbers := []*int{&v.major, &v.minor, &v.patch}
+	parts := strings.Split(s, ".")
+	if len(parts) > 3 {
+		return 0, v, false
+	}
+	for kk, part := range parts {
+		if part == "x" || part == "X" || part == "*" {
+			if v.pre != nil {
+				return 0, v, false
+			}
+			return kk, v, true
+		}
+		n, ok := versionNumber(part)
+		if !ok {
+			return 0, v, false
+		}
+		*numbers[kk] = n
+	}
+	if len(parts) < 3 && v.pre != nil {
+		return 0, v, false
+	}
+	return len(parts), v, true
+}
+
+// versionNumber parses a number without leading zeros
+func versionNumber(s string) (int, bool) {
+	if s == "" || len(s) > 1 && s[0] == '0' {
+		return 0, false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+	n, err := strconv.Atoi(s)
+	return n, err == nil
+}
+
+func validIdentifiers(s string, pre bool) bool {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return false
+		}
+		digits := true
+		for _, r := range id {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				digits = false
+			default:
+				return false
+			}
+		}
+		if pre && digits && len(id) > 1 && id[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
+
+func (v version) compare(o version) int {
+	if c := sign(v.major - o.major); c != 0 {
+		return c
+	}
+	if c := sign(v.minor - o.minor); c != 0 {
+		return c
+	}
+	if c := sign(v.patch - o.patch); c != 0 {
+		return c
+	}
+
+	// a pre-release version is before the release
+	switch {
+	case len(v.pre) == 0 && len(o.pre) == 0:
+		return 0
+	case len(v.pre) == 0:
+		return 1
+	case len(o.pre) == 0:
+		return -1
+	}
+
+	for kk := 0; kk < len(v.pre) && kk < len(o.pre); kk++ {
+		x, xok := versionNumber(v.pre[kk])
+		y, yok := versionNumber(o.pre[kk])
+		c := 0
+		switch {
+		case xok && yok:
+			c = sign(x - y)
+		case xok:
+			c = -1
+		case yok:
+			c = 1
+		default:
+			c = strings.Compare(v.pre[kk], o.pre[kk])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return sign(len(v.pre) - len(o.pre))
+}
+
+func (v version) sameRelease(o version) bool {
+	return v.major == o.major && v.minor == o.minor && v.patch == o.patch
+}
+
+// bump returns the smallest version (including pre-releases) after
+// all versions matching the first n parts of v
+func (v version) bump(n int) version {
+	switch n {
+	case 0:
+		return version{major: 1 << 30, pre: []string{"0"}}
+	case 1:
+		return version{major: v.major + 1, pre: []string{"0"}}
+	case 2:
+		return version{major: v.major, minor: v.minor + 1, pre: []string{"0"}}
+	}
+	return version{major: v.major, minor: v.minor, patch: v.patch + 1, pre: []string{"0"}}
+}
+
+// comparator is a single constraint like >=1.2.3
+type comparator struct {
+	op string
+	v  version
+}
+
+func (c comparator) matches(v version) bool {
+	cmp := v.compare(c.v)
+	switch c.op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+// versionRange is a union of sets of comparators which must all
+// match.  The syntax follows npm: "^2.3 || >=3.1.0-beta <4".
+type versionRange [][]comparator
+
+func (r versionRange) contains(v version) bool {
+	for _, set := range r {
+		if matchesAll(set, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAll checks all the comparators of a set.  As with npm,
+// pre-release versions only match if some comparator has a
+// pre-release of the same version.
+func matchesAll(set []comparator, v version) bool {
+	prerelease := len(v.pre) == 0
+	for _, c := range set {
+		if !c.matches(v) {
+			return false
+		}
+		if len(c.v.pre) > 0 && c.v.sameRelease(v) && c.v.pre[0] != "0" {
+			prerelease = true
+		}
+	}
+	return prerelease
+}
+
+func parseRange(s string) (versionRange, bool) {
+	var result versionRange
+	for _, alt := range strings.Split(s, "||") {
+		set, ok := parseSet(strings.Fields(alt))
+		if !ok {
+			return nil, false
+		}
+		result = append(result, set)
+	}
+	return result, true
+}
+
+func parseSet(tokens []string) ([]comparator, bool) {
+	if len(tokens) == 3 && tokens[1] == "-" {
+		return parseHyphen(tokens[0], tokens[2])
+	}
+
+	result := []comparator{}
+	for kk := 0; kk < len(tokens); kk++ {
+		token := tokens[kk]
+		if isRangeOp(token) && kk+1 < len(tokens) {
+			// allow spaces between the operator and the version
+			kk++
+			token += tokens[kk]
+		}
+		set, ok := parseComparator(token)
+		if !ok {
+			return nil, false
+		}
+		result = append(result, set...)
+	}
+	return result, true
+}
+
+func isRangeOp(s string) bool {
+	switch s {
+	case "<", "<=", ">", ">=", "=", "~", "^":
+		return true
+	}
+	return false
+}
+
+func parseHyphen(from, to string) ([]comparator, bool) {
+	_, lo, ok1 := parsePartial(from)
+	n, hi, ok2 := parsePartial(to)
+	if !ok1 || !ok2 {
+		return nil, false
+	}
+	upper := comparator{"<=", hi}
+	if n < 3 {
+		upper = comparator{"<", hi.bump(n)}
+	}
+	return []comparator{{">=", lo}, upper}, true
+}
+
+// parseComparator converts a single comparator, tilde, caret or
+// x-range into primitive comparators
+func parseComparator(s string) ([]comparator, bool) {
+	op := ""
+	for _, prefix := range []string{"<=", ">=", "<", ">", "=", "~", "^"} {
+		if strings.HasPrefix(s, prefix) {
+			op, s = prefix, s[len(prefix):]
+			break
+		}
+	}
+	if s == "" && op == "" || s == "*" {
+		return []comparator{}, op == "" || op == ">=" || op == "="
+	}
+
+	n, v, ok := parsePartial(s)
+	if !ok {
+		return nil, false
+	}
+
+	switch op {
+	case "", "=":
+		if n == 3 {
+			return []comparator{{"=", v}}, true
+		}
+		if n == 0 {
+			return []comparator{}, true
+		}
+		return []comparator{{">=", v}, {"<", v.bump(n)}}, true
+	case "~":
+		if n == 3 {
+			n = 2
+		}
+		return []comparator{{">=", v}, {"<", v.bump(n)}}, true
+	case "^":
+		// the first non-zero part is fixed
+		fixed := 1
+		switch {
+		case v.major == 0 && n >= 2 && v.minor == 0 && n == 3:
+			fixed = 3
+		case v.major == 0 && n >= 2:
+			fixed = 2
+		}
+		if n < fixed {
+			fixed = n
+		}
+		return []comparator{{">=", v}, {"<", v.bump(fixed)}}, true
+	case ">":
+		if n < 3 {
+			return []comparator{{">=", v.bump(n)}}, true
+		}
+	case "<=":
+		if n < 3 {
+			return []comparator{{"<", v.bump(n)}}, true
+		}
+	case "<":
+		if n < 3 {
+			v.pre = []string{"0"}
+		}
+	}
+	return []comparator{{op, v}}, true
+}
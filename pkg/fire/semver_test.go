@@ -0,0 +1,71 @@
+package fire_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestSemver(t *testing.T) {
+	ctx := context.Background()
+	n, b := fire.Number, fire.Bool
+
+	suite := map[string]fire.Value{
+		`semver.compare("2.10.0", "2.9.0")`:                   n(1),
+		`semver.compare(x = "v1.2.3", y = "1.2.3+build.5")`:   n(0),
+		`semver.compare("1.0.0-alpha", "1.0.0")`:              n(-1),
+		`semver.compare("1.0.0-alpha", "1.0.0-alpha.1")`:      n(-1),
+		`semver.compare("1.0.0-alpha.1", "1.0.0-alpha.beta")`: n(-1),
+		`semver.compare("1.0.0-beta.2", "1.0.0-beta.11")`:     n(-1),
+		`semver.compare("1.0.0-rc.1", "1.0.0-beta.11")`:       n(1),
+		`semver.compare("2.9", "2.10.0")`:                     fire.Error(`semver.compare: invalid version "2.9"`),
+		`semver.compare(2, "2.10.0")`:                         fire.Error(`semver.compare: not a string`),
+		`semver.compare(error("boo"), "1.0.0")`:               fire.Error("boo"),
+		`semver.valid("1.2.3-rc.1+sha.5114f85")`:              b(true),
+		`semver.valid("1.02.3")`:                              b(false),
+		`semver.valid("1.2.3-01")`:                            b(false),
+		`semver.valid("1.2.3-")`:                              b(false),
+		`semver.valid(3)`:                                     b(false),
+		`semver.parse("1.2.3-rc.1+sha").minor`:                n(2),
+		`semver.parse("1.2.3-rc.1+sha").prerelease`:           fire.String("rc.1"),
+		`semver.parse("1.2.3-rc.1+sha").build`:                fire.String("sha"),
+		`semver.parse("x")`:                                   fire.Error(`semver.parse: invalid version "x"`),
+
+		`semver.satisfies("2.3.1", "^2.3")`:                             b(true),
+		`semver.satisfies("2.10.0", "^2.3")`:                            b(true),
+		`semver.satisfies("3.0.0", "^2.3")`:                             b(false),
+		`semver.satisfies("3.0.0", "^2.3 || >=3.1.0-beta")`:             b(false),
+		`semver.satisfies("3.1.0-beta.2", "^2.3 || >=3.1.0-beta")`:      b(true),
+		`semver.satisfies("3.2.0", "^2.3 || >=3.1.0-beta")`:             b(true),
+		`semver.satisfies("3.2.0-beta", "^2.3 || >=3.1.0-beta")`:        b(false),
+		`semver.satisfies("0.2.5", "^0.2.3")`:                           b(true),
+		`semver.satisfies("0.3.0", "^0.2.3")`:                           b(false),
+		`semver.satisfies("0.0.4", "^0.0.3")`:                           b(false),
+		`semver.satisfies("1.2.9", "~1.2.3")`:                           b(true),
+		`semver.satisfies("1.3.0", "~1.2.3")`:                           b(false),
+		`semver.satisfies("1.9.0", "1.x")`:                              b(true),
+		`semver.satisfies("1.2.3", "*")`:                                b(true),
+		`semver.satisfies("1.2.3", "1.2.3")`:                            b(true),
+		`semver.satisfies("2.3.5", "1.2.3 - 2.3")`:                      b(true),
+		`semver.satisfies("2.4.0", "1.2.3 - 2.3")`:                      b(false),
+		`semver.satisfies("1.5.0", ">= 1.2 < 2")`:                       b(true),
+		`semver.satisfies("1.2.0", ">1.2")`:                             b(false),
+		`semver.satisfies("1.2.9", "<=1.2")`:                            b(true),
+		`semver.satisfies(version = "1.2.3", range = "<1.2.3 || >1.1")`: b(true),
+		`semver.satisfies("1.2.3", "~>1.2")`:                            fire.Error(`semver.satisfies: invalid range "~>1.2"`),
+		`semver.satisfies("1.2.3", 5)`:                                  fire.Error(`semver.satisfies: range is not a string`),
+		`semver.satisfies("1.2", "^1")`:                                 fire.Error(`semver.satisfies: invalid version "1.2"`),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+}
@@ -0,0 +1,630 @@
+package fire
+
+import (
+	"context"
+	"sync"
+)
+
+// Stream creates a stream with the provided initial value.  If the
+// value is already a stream, it is returned as is.
+//
+// Streams are values that change over time.  Operations on streams
+// (such as `x + 1`) result in streams which track the inputs.  See
+// Replace for changing the value of a stream and Watch for getting
+// notified of changes.
+func Stream(v Value) Value {
+	if s, ok := v.(*stream); ok {
+		return s
+	}
+	return &stream{value: v}
+}
+
+// Replace changes the value of a stream.  The change is propagated
+// upstream if possible (such as replacing a field of an object
+// stream whose value came from another stream).  If that is not
+// possible, the stream is redefined to hold the new value.
+//
+// Replace returns the stream or an error if s is not a stream.
+func Replace(ctx context.Context, s, v Value) Value {
+	st, ok := s.(*stream)
+	if !ok {
+		return newError("not a stream")
+	}
+	ctx, affected := withEdits(ctx)
+	st.edit(ctx, v)
+	affected.notify()
+	return st
+}
+
+// Snapshot returns the current value of a stream.  Objects and lists
+// with streams in them are converted as well.  Other values are
+// returned as is.
+func Snapshot(ctx context.Context, v Value) Value {
+	result, _ := snapshot(ctx, v)
+	return result
+}
+
+// snapshot is like Snapshot but also reports if v had any streams
+func snapshot(ctx context.Context, v Value) (Value, bool) {
+	switch v := v.(type) {
+	case *stream:
+		result, _ := snapshot(ctx, v.current(ctx))
+		return result, true
+	case namedArgs:
+		result, ok := snapshot(ctx, v.obj)
+		return namedArgs{result.(obj)}, ok
+	case obj:
+		var result obj
+		for k, val := range v {
+			if snap, ok := snapshot(ctx, val); ok {
+				if result == nil {
+					result = v.copy()
+				}
+				result[k] = snap
+			}
+		}
+		if result != nil {
+			return result, true
+		}
+	case listValue:
+		var result listValue
+		for kk, elt := range v {
+			if snap, ok := snapshot(ctx, elt); ok {
+				if result == nil {
+					result = append(listValue(nil), v...)
+				}
+				result[kk] = snap
+			}
+		}
+		if result != nil {
+			return result, true
+		}
+	}
+	return v, false
+}
+
+// streams guards the state of all streams as well as the streams
+// each watcher is registered with.
+var streams sync.Mutex
+
+// stream is a value that changes over time.
+//
+// Source streams (and streams which have been redefined) hold their
+// value directly.  Derived streams compute their value from other
+// streams and cache it until one of the streams read changes.  Edits
+// of derived streams are propagated upstream using back, if possible.
+type stream struct {
+	value   Value
+	compute func(ctx context.Context) Value
+	back    func(ctx context.Context, v Value) bool
+
+	// version is incremented whenever the definition changes
+	version int
+
+	// deps holds the versions of all the streams read, directly or
+	// not, when the cached value was computed
+	deps  map[*stream]int
+	valid bool
+
+	// watchers are notified when the definition changes
+	watchers map[*Watcher]bool
+}
+
+// derived creates a stream whose value is computed from other
+// streams.  The back function, if not nil, propagates edits upstream
+// and returns false if that is not possible.
+func derived(compute func(ctx context.Context) Value, back func(ctx context.Context, v Value) bool) *stream {
+	return &stream{compute: compute, back: back}
+}
+
+// current returns the current value of the stream.  This is never a
+// stream itself but objects and lists may have streams within them.
+//
+// The stream and the streams it depends on are recorded as read (see
+// withReads).
+func (s *stream) current(ctx context.Context) Value {
+	streams.Lock()
+	compute, version := s.compute, s.version
+	if compute == nil || s.fresh() {
+		v := s.value
+		s.read(ctx, version, s.deps)
+		streams.Unlock()
+		return v
+	}
+	streams.Unlock()
+
+	inner, deps := withReads(ctx)
+	v := compute(inner)
+	for {
+		st, ok := v.(*stream)
+		if !ok {
+			break
+		}
+		v = st.current(inner)
+	}
+
+	streams.Lock()
+	defer streams.Unlock()
+	if s.compute != nil && s.version == version {
+		s.value, s.deps, s.valid = v, deps, true
+	}
+	s.read(ctx, version, deps)
+	return v
+}
+
+// fresh checks if the cached value of a derived stream is still
+// valid.  streams must be locked.
+func (s *stream) fresh() bool {
+	if !s.valid {
+		return false
+	}
+	for dep, version := range s.deps {
+		if dep.version != version {
+			return false
+		}
+	}
+	return true
+}
+
+// read records the stream and its deps with the reads of ctx, if
+// any.  streams must be locked.
+func (s *stream) read(ctx context.Context, version int, deps map[*stream]int) {
+	reads, _ := ctx.Value(readsKey{}).(map[*stream]int)
+	if reads == nil {
+		return
+	}
+	reads[s] = version
+	for dep, v := range deps {
+		reads[dep] = v
+	}
+}
+
+// changed invalidates the stream after its definition has changed
+// and collects its watchers with the edits of ctx.  streams must be
+// locked.
+func (s *stream) changed(ctx context.Context) {
+	s.version++
+	s.valid = false
+	if affected, ok := ctx.Value(editsKey{}).(*edits); ok {
+		for w := range s.watchers {
+			affected.watchers[w] = true
+		}
+	}
+}
+
+type readsKey struct{}
+
+// withReads returns a context which records the versions of all the
+// streams read with it
+func withReads(ctx context.Context) (context.Context, map[*stream]int) {
+	reads := map[*stream]int{}
+	return context.WithValue(ctx, readsKey{}, reads), reads
+}
+
+type editsKey struct{}
+
+// edits holds the watchers affected by a set of edits
+type edits struct {
+	watchers map[*Watcher]bool
+}
+
+// withEdits returns a context which collects the watchers affected
+// by the edits made with it.  Edits are not reads, so the reads of
+// ctx are not recorded any further.
+func withEdits(ctx context.Context) (context.Context, *edits) {
+	affected := &edits{watchers: map[*Watcher]bool{}}
+	ctx = context.WithValue(ctx, readsKey{}, map[*stream]int(nil))
+	return context.WithValue(ctx, editsKey{}, affected), affected
+}
+
+// notify checks the affected watchers.  This happens in the calling
+// goroutine.
+func (e *edits) notify() {
+	streams.Lock()
+	watchers := make([]*Watcher, 0, len(e.watchers))
+	for w := range e.watchers {
+		watchers = append(watchers, w)
+	}
+	streams.Unlock()
+
+	for _, w := range watchers {
+		w.Check()
+	}
+}
+
+// edit changes the value of the stream without notifying watchers
+func (s *stream) edit(ctx context.Context, v Value) {
+	v = Snapshot(ctx, v)
+	streams.Lock()
+	back := s.back
+	streams.Unlock()
+
+	if back == nil || !back(ctx, v) {
+		s.define(ctx, v)
+	}
+}
+
+// define replaces the definition of the stream with a constant
+func (s *stream) define(ctx context.Context, v Value) {
+	streams.Lock()
+	defer streams.Unlock()
+	s.value, s.compute, s.back, s.deps = v, nil, nil, nil
+	s.changed(ctx)
+}
+
+func (s *stream) Code(ctx context.Context) string {
+	return "sys.streams.new(" + Snapshot(ctx, s).Code(ctx) + ")"
+}
+
+func (s *stream) HashCode() interface{} {
+	return s
+}
+
+// Call calls the current value of the stream, resulting in a stream.
+// Named args are only passed as such to methods (see methodArg).
+func (s *stream) Call(ctx context.Context, args ...Value) Value {
+	return derived(func(ctx context.Context) Value {
+		fn, args := s.current(ctx), snapshots(ctx, args)
+		if _, ok := fn.(methodFn); !ok && len(args) == 1 {
+			if named, ok := args[0].(namedArgs); ok {
+				args = []Value{named.obj}
+			}
+		}
+		return fn.Call(ctx, args...)
+	}, nil)
+}
+
+// Lookup returns a stream which tracks the field.  Replacing the
+// value of the field stream edits the parent stream.  The `replace`
+// method is an exception: it replaces the value of the whole stream.
+func (s *stream) Lookup(ctx context.Context, field Value) Value {
+	if name, _ := field.String(ctx); name == "replace" {
+		return method(s, name, func(ctx context.Context, args ...Value) Value {
+			v, ok := methodArg(args, "it")
+			if !ok {
+				return newError("replace requires one arg")
+			}
+			return Replace(ctx, s, v)
+		})
+	}
+
+	return derived(func(ctx context.Context) Value {
+		return s.current(ctx).Lookup(ctx, field)
+	}, func(ctx context.Context, v Value) bool {
+		parent := s.current(ctx)
+		if inner, ok := parent.Lookup(ctx, field).(*stream); ok {
+			inner.edit(ctx, v)
+			return true
+		}
+		switch parent := parent.(type) {
+		case obj:
+			updated := parent.copy()
+			updated[field] = v
+			s.edit(ctx, updated)
+			return true
+		case listValue:
+			f, ok := field.Number(ctx)
+			idx := int(f)
+			if !ok || float64(idx) != f || idx < 0 || idx >= len(parent) {
+				return false
+			}
+			updated := append(listValue(nil), parent...)
+			updated[idx] = v
+			s.edit(ctx, updated)
+			return true
+		}
+		return false
+	})
+}
+
+func (s *stream) Equals(ctx context.Context, other Value) bool {
+	if s == other {
+		return true
+	}
+	return Snapshot(ctx, s).Equals(ctx, Snapshot(ctx, other))
+}
+
+func (s *stream) Number(ctx context.Context) (float64, bool) {
+	return s.current(ctx).Number(ctx)
+}
+
+func (s *stream) String(ctx context.Context) (string, bool) {
+	return s.current(ctx).String(ctx)
+}
+
+func (s *stream) Bool(ctx context.Context) (bool, bool) {
+	return s.current(ctx).Bool(ctx)
+}
+
+func (s *stream) Error(ctx context.Context) (error, bool) {
+	return s.current(ctx).Error(ctx)
+}
+
+// lift makes a function reactive: if any of its args is a stream,
+// the result is a stream computed from the current values of the
+// args.
+func lift(fn func(ctx context.Context, args ...Value) Value) func(ctx context.Context, args ...Value) Value {
+	return func(ctx context.Context, args ...Value) Value {
+		if !hasStreams(args) {
+			return fn(ctx, args...)
+		}
+		return derived(func(ctx context.Context) Value {
+			return fn(ctx, snapshots(ctx, args)...)
+		}, nil)
+	}
+}
+
+func hasStreams(args []Value) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*stream); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func snapshots(ctx context.Context, args []Value) []Value {
+	result := make([]Value, len(args))
+	for kk, arg := range args {
+		result[kk] = Snapshot(ctx, arg)
+	}
+	return result
+}
+
+// objectStream creates a stream of objects whose fields may be
+// streams.  Edits of fields that are streams are propagated to them
+// while edits of the other fields change the definition.
+func objectStream(fields obj) *stream {
+	var result *stream
+	result = derived(func(ctx context.Context) Value {
+		streams.Lock()
+		defer streams.Unlock()
+		return fields
+	}, func(ctx context.Context, v Value) bool {
+		o, ok := v.(obj)
+		if !ok {
+			return false
+		}
+
+		streams.Lock()
+		old := fields
+		streams.Unlock()
+
+		updated := obj{}
+		for k, val := range o {
+			if inner, ok := old[k].(*stream); ok {
+				inner.edit(ctx, val)
+				val = inner
+			}
+			updated[k] = val
+		}
+
+		streams.Lock()
+		defer streams.Unlock()
+		fields = updated
+		result.changed(ctx)
+		return true
+	})
+	return result
+}
+
+// transform creates a stream which tracks s but calls the handler
+// with the stream whenever the value of s changes.  Edits made by
+// the handler do not cause the handler to be called again.
+//
+// The handler is called when the stream is read, so changes are only
+// noticed while the stream is being watched (see Watch).
+func transform(ctx context.Context, s *stream, handler Value) *stream {
+	last := Snapshot(ctx, s)
+	running := false
+
+	var result *stream
+	result = derived(func(ctx context.Context) Value {
+		current := Snapshot(ctx, s)
+
+		streams.Lock()
+		changed := !running && !current.Equals(ctx, last)
+		if changed {
+			running = true
+		} else if !running {
+			last = current
+		}
+		streams.Unlock()
+
+		if changed {
+			err := handler.Call(ctx, result)
+			current = Snapshot(ctx, s)
+			streams.Lock()
+			running, last = false, current
+			streams.Unlock()
+			if isError(ctx, err) {
+				return err
+			}
+		}
+		return s.current(ctx)
+	}, func(ctx context.Context, v Value) bool {
+		s.edit(ctx, v)
+		return true
+	})
+
+	// register the initial state
+	result.current(ctx)
+	return result
+}
+
+func isError(ctx context.Context, v Value) bool {
+	_, ok := v.Error(ctx)
+	return ok
+}
+
+// Watcher calls a function whenever a value changes.  See Watch.
+type Watcher struct {
+	eval     func(ctx context.Context) Value
+	onChange func(Value)
+
+	sync.Mutex
+	last          Value
+	busy, pending bool
+	cancelled     bool
+
+	// reads holds the streams read by the last check.  This is
+	// guarded by streams.
+	reads map[*stream]int
+}
+
+// Watch calls onChange with the result of eval and then again every
+// time the result changes.
+//
+// Streams must be read using the context passed to eval.  The result
+// is checked again when any of the streams read by the last check is
+// replaced (as well as when Check is called).  This happens in the
+// goroutine calling Replace, so eval should be cheap.  Calls to
+// onChange are not concurrent and are made in the order of changes.
+func Watch(eval func(ctx context.Context) Value, onChange func(Value)) *Watcher {
+	w := &Watcher{eval: eval, onChange: onChange}
+	w.Check()
+	return w
+}
+
+// Check evaluates the watched value, calling onChange if it has
+// changed.  This is useful when the value depends on things other
+// than streams.
+func (w *Watcher) Check() {
+	w.Lock()
+	if w.busy {
+		w.pending = true
+		w.Unlock()
+		return
+	}
+	w.busy = true
+
+	for !w.cancelled {
+		w.pending = false
+		w.Unlock()
+		ctx, reads := withReads(context.Background())
+		v := Snapshot(ctx, w.eval(ctx))
+		w.Lock()
+		if w.cancelled {
+			break
+		}
+		if w.watch(reads) {
+			// a stream changed before w was registered with it
+			w.pending = true
+		}
+		if w.last == nil || !v.Equals(context.Background(), w.last) {
+			w.last = v
+			w.Unlock()
+			w.onChange(v)
+			w.Lock()
+		}
+		if !w.pending {
+			break
+		}
+	}
+	w.busy = false
+	w.Unlock()
+}
+
+// Cancel stops watching.  The callback is not called after Cancel
+// returns unless Cancel was called from the callback itself.
+func (w *Watcher) Cancel() {
+	w.Lock()
+	w.cancelled = true
+	w.watch(nil)
+	w.Unlock()
+}
+
+// watch registers w with the streams read, dropping the earlier
+// registrations.  It returns true if any of the streams has changed
+// since it was read.
+func (w *Watcher) watch(reads map[*stream]int) bool {
+	streams.Lock()
+	defer streams.Unlock()
+
+	for s := range w.reads {
+		if _, ok := reads[s]; !ok {
+			delete(s.watchers, w)
+		}
+	}
+
+	stale := false
+	for s, version := range reads {
+		if s.watchers == nil {
+			s.watchers = map[*Watcher]bool{}
+		}
+		s.watchers[w] = true
+		stale = stale || s.version != version
+	}
+	w.reads = reads
+	return stale
+}
+
+// streamFn is a builtin which takes streams as args instead of
+// being lifted like other builtins
+type streamFn struct {
+	builtinFn
+}
+
+func (f streamFn) NativeCall(ctx context.Context, args []interface{}, scope Value) Value {
+	values, err := f.values(ctx, args, scope)
+	if err != nil {
+		return err
+	}
+	return f.Call(ctx, values...)
+}
+
+func (f streamFn) Call(ctx context.Context, args ...Value) Value {
+	if err := f.check(args); err != nil {
+		return err
+	}
+	return f.fn(ctx, args)
+}
+
+// sysObject is the `sys` global
+func sysObject() Value {
+	return Object(map[Value]Value{
+		String("streams"): Object(map[Value]Value{
+			String("new"): streamFn{builtinFn{"sys.streams.new", []string{"s"}, func(ctx context.Context, args []Value) Value {
+				return Stream(Snapshot(ctx, args[0]))
+			}}},
+			String("replace"): streamFn{builtinFn{"sys.streams.replace", []string{"s", "value"}, func(ctx context.Context, args []Value) Value {
+				s, err := streamArg(ctx, "sys.streams.replace", args[0])
+				if err != nil {
+					return err
+				}
+				v := Snapshot(ctx, args[1])
+				ctx, affected := withEdits(ctx)
+				s.define(ctx, v)
+				affected.notify()
+				return s
+			}}},
+			String("snapshot"): streamFn{builtinFn{"sys.streams.snapshot", []string{"s"}, func(ctx context.Context, args []Value) Value {
+				return Snapshot(ctx, args[0])
+			}}},
+			String("readonly"): streamFn{builtinFn{"sys.streams.readonly", []string{"s"}, func(ctx context.Context, args []Value) Value {
+				if s, ok := args[0].(*stream); ok {
+					return derived(s.current, nil)
+				}
+				return args[0]
+			}}},
+			String("transform"): streamFn{builtinFn{"sys.streams.transform", []string{"s", "handler"}, func(ctx context.Context, args []Value) Value {
+				s, err := streamArg(ctx, "sys.streams.transform", args[0])
+				if err != nil {
+					return err
+				}
+				return transform(ctx, s, args[1])
+			}}},
+		}),
+	})
+}
+
+func streamArg(ctx context.Context, code string, v Value) (*stream, Value) {
+	if isError(ctx, v) {
+		return nil, v
+	}
+	s, ok := v.(*stream)
+	if !ok {
+		return nil, newError(code + ": not a stream")
+	}
+	return s, nil
+}
@@ -0,0 +1,179 @@
+package fire_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestStreams(t *testing.T) {
+	ctx := context.Background()
+	x := fire.Stream(fire.Number(1))
+	scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("x"), x})
+	eval := func(code string) fire.Value {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		return fire.Eval(ctx, parsed, scope)
+	}
+	check := func(v, expected fire.Value) {
+		t.Helper()
+		if !v.Equals(ctx, expected) {
+			t.Error("Unexpected value", v.Code(ctx), expected.Code(ctx))
+		}
+	}
+	replace := func(s fire.Value, v fire.Value) {
+		t.Helper()
+		if _, ok := fire.Replace(ctx, s, v).Error(ctx); ok {
+			t.Fatal("Replace failed")
+		}
+	}
+
+	y := eval(`x * 2 + 1`)
+	z := eval(`object(a = x, b = 2)`)
+	big := eval(`math.max(x, 3)`)
+	ro := eval(`sys.streams.readonly(x)`)
+	check(y, fire.Number(3))
+	check(big, fire.Number(3))
+
+	replace(x, fire.Number(5))
+	check(y, fire.Number(11))
+	check(big, fire.Number(5))
+	check(z.Lookup(ctx, fire.String("a")), fire.Number(5))
+	check(ro, fire.Number(5))
+
+	// edits of object fields propagate to the field streams
+	replace(z.Lookup(ctx, fire.String("a")), fire.Number(10))
+	check(x, fire.Number(10))
+	check(y, fire.Number(21))
+
+	// edits of constant fields change the definition
+	replace(z.Lookup(ctx, fire.String("b")), fire.Number(3))
+	replace(x, fire.Number(4))
+	check(fire.Snapshot(ctx, z), eval(`object(a = 4, b = 3)`))
+
+	replace(z, eval(`object(a = 7, b = 1)`))
+	check(x, fire.Number(7))
+	check(fire.Snapshot(ctx, z), eval(`object(a = 7, b = 1)`))
+
+	// derived values that cannot propagate are redefined
+	replace(y, fire.Number(100))
+	check(y, fire.Number(100))
+	check(x, fire.Number(7))
+	replace(x, fire.Number(8))
+	check(y, fire.Number(100))
+
+	// readonly streams do not propagate
+	replace(ro, fire.Number(0))
+	check(x, fire.Number(8))
+	check(ro, fire.Number(0))
+
+	// explicit redefinition does not propagate either
+	scope = fire.Scope(ctx, scope, [2]fire.Value{fire.String("z"), z})
+	eval(`sys.streams.replace(z, object(a = 1))`)
+	check(x, fire.Number(8))
+	check(fire.Snapshot(ctx, z), eval(`object(a = 1)`))
+
+	check(eval(`x.replace(2)`), fire.Number(2))
+	check(eval(`sys.streams.snapshot(x.replace(x + 1))`), fire.Number(3))
+	check(eval(`x.replace(it = 2)`), fire.Number(2))
+
+	suite := map[string]fire.Value{
+		`sys.streams.new(x) == x`:                                        fire.Bool(true),
+		`sys.streams.snapshot(object(a = x, b = 1))`:                     eval(`object(a = 2, b = 1)`),
+		`sys.streams.snapshot(list(x, x + 1))`:                           fire.List(fire.Number(2), fire.Number(3)),
+		`sys.streams.new(s = 5)`:                                         fire.Number(5),
+		`if(x > 1, "big", "small")`:                                      fire.String("big"),
+		`sys.streams.replace(5, 2)`:                                      fire.Error("sys.streams.replace: not a stream"),
+		`sys.streams.transform(x)`:                                       fire.Error("sys.streams.transform requires s, handler"),
+		`sys.streams.new(object(a = 1)).a.replace(3)`:                    fire.Number(3),
+		`sys.streams.new(list(1, 2)).(1).replace(3)`:                     fire.Number(3),
+		`sys.streams.new("hello").upper()`:                               fire.String("HELLO"),
+		`sys.streams.new("hello").length`:                                fire.Number(5),
+		`sys.streams.new(list(3, 1, 2)).sort(by = {0 - it})`:             fire.List(fire.Number(3), fire.Number(2), fire.Number(1)),
+		`sys.streams.new(list(object(it = 1))).contains(object(it = 1))`: fire.Bool(true),
+		`sys.streams.new({it.a + x})(a = 5)`:                             fire.Number(7),
+	}
+	for k, v := range suite {
+		check(eval(k), v)
+	}
+
+	if got := fire.ToNative(ctx, z); !reflect.DeepEqual(got, map[interface{}]interface{}{"a": 1.0}) {
+		t.Error("Unexpected native value", got)
+	}
+}
+
+func TestStreamsWatch(t *testing.T) {
+	ctx := context.Background()
+	x := fire.Stream(fire.Number(1))
+	scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("x"), x})
+	parsed, _ := parse.String(`if(x > 2, "big", "small")`)
+
+	seen := []fire.Value{}
+	w := fire.Watch(func(ctx context.Context) fire.Value {
+		return fire.Eval(ctx, parsed, scope)
+	}, func(v fire.Value) {
+		seen = append(seen, v)
+	})
+
+	fire.Replace(ctx, x, fire.Number(2))
+	fire.Replace(ctx, x, fire.Number(3))
+	fire.Replace(ctx, x, fire.Number(4))
+	w.Cancel()
+	fire.Replace(ctx, x, fire.Number(0))
+
+	expected := []fire.Value{fire.String("small"), fire.String("big")}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Error("Unexpected changes", seen)
+	}
+
+	// only the streams read by the last check cause it to be checked again
+	y := fire.Stream(fire.Number(1))
+	evals := 0
+	w = fire.Watch(func(ctx context.Context) fire.Value {
+		evals++
+		return fire.Eval(ctx, parsed, scope)
+	}, func(v fire.Value) {})
+	defer w.Cancel()
+	fire.Replace(ctx, y, fire.Number(2))
+	fire.Replace(ctx, x, fire.Number(5))
+	if evals != 2 {
+		t.Error("Unexpected evaluations", evals)
+	}
+}
+
+func TestStreamsTransform(t *testing.T) {
+	ctx := context.Background()
+	x := fire.Stream(fire.Number(1))
+	y := fire.Stream(fire.Number(1))
+	it := fire.Object(map[fire.Value]fire.Value{fire.String("x"): x, fire.String("y"): y})
+	scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("arg"), it})
+	parsed, errs := parse.String(`{delta_count(it), where(delta_count = {
+		sys.streams.transform(s = object(x = it.x, y = it.y, result = sys.streams.new(0)), handler = xform).result,
+		where(xform = {it.result.replace(it.result + 1)})
+	})}(arg)`)
+	if len(errs) > 0 {
+		t.Fatal("Unexpected parse error", errs)
+	}
+	count := fire.Eval(ctx, parsed, scope)
+
+	seen := []fire.Value{}
+	w := fire.Watch(func(ctx context.Context) fire.Value { return count }, func(v fire.Value) {
+		seen = append(seen, v)
+	})
+	defer w.Cancel()
+
+	fire.Replace(ctx, x, fire.Number(2))
+	fire.Replace(ctx, y, fire.Number(2))
+	fire.Replace(ctx, x, fire.Number(2))
+	fire.Replace(ctx, x, fire.Number(3))
+
+	expected := []fire.Value{fire.Number(0), fire.Number(1), fire.Number(2), fire.Number(3)}
+	if !reflect.DeepEqual(seen, expected) {
+		t.Error("Unexpected changes", seen)
+	}
+}
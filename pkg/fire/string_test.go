@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
 )
 
 func TestString(t *testing.T) {
@@ -17,6 +18,16 @@ func TestString(t *testing.T) {
 		t.Fatal("code failed", c)
 	}
 
+	for _, s := range []string{`a\`, `a\\`, `\"`, `a\"b\`} {
+		parsed, errs := parse.String(fire.String(s).Code(ctx))
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", s, errs)
+		}
+		if v := fire.Eval(ctx, parsed, fire.Globals()); !v.Equals(ctx, fire.String(s)) {
+			t.Error("Code did not round trip", s, v)
+		}
+	}
+
 	if fire.String("ok").HashCode() == fire.String("yo").HashCode() {
 		t.Fatal("Hash codes were same")
 	}
@@ -50,3 +61,51 @@ func TestString(t *testing.T) {
 		t.Fatal("error check failed")
 	}
 }
+
+func TestStringMethods(t *testing.T) {
+	ctx := context.Background()
+	s, n, b := fire.String, fire.Number, fire.Bool
+
+	suite := map[string]fire.Value{
+		`"héllo".length`:  n(5),
+		`"Hello".lower()`: s("hello"),
+		`"Hello".upper()`: s("HELLO"),
+		`"  hi ".trim()`:  s("hi"),
+		`"boo@example.com".endsWith("@example.com")`:   b(true),
+		`"boo@example.com".endsWith(suffix = ".org")`:  b(false),
+		`"boo@example.com".startsWith("boo")`:          b(true),
+		`"boo@example.com".contains("@")`:              b(true),
+		`"boo@example.com".contains(5)`:                fire.Error("substring must be a string"),
+		`"a,b".split(",")`:                             fire.List(s("a"), s("b")),
+		`"boo@example.com".split("@").(1)`:             s("example.com"),
+		`"héllo".slice(1)`:                             s("éllo"),
+		`"hello".slice(start = 1, end = 3)`:            s("el"),
+		`"hello".slice(end = 2)`:                       s("he"),
+		`"hello".slice(start = 4, end = 10)`:           s("o"),
+		`"hello".slice(start = 3, end = 1)`:            s(""),
+		`"a-b-c".replace(old = "-", new = "+")`:        s("a+b+c"),
+		`"a-b-c".replace("-")`:                         fire.Error("missing old"),
+		`"boo@example.com".matches("^[a-z]+@example")`: b(true),
+		`"boo@example.com".matches(pattern = "^hoo")`:  b(false),
+		`"boo".matches("(")`:                           fire.Error("error parsing regexp: missing closing ): `(`"),
+		`"hi {name}, {n}".format(name = "boo", n = 5)`: s("hi boo, 5"),
+		`"{{it}} is {it}".format(5)`:                   s("{it} is 5"),
+		`"hi {name}".format(x = 1)`:                    fire.Error("format: missing name"),
+		`"hi {name".format(name = 1)`:                  fire.Error("format: unclosed {"),
+		`"boo".len`:                                    fire.Error("cannot lookup a string"),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+
+	if code := fire.String("boo").Lookup(ctx, s("upper")).Code(ctx); code != `"boo".upper` {
+		t.Error("Unexpected code", code)
+	}
+}
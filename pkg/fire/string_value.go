@@ -3,6 +3,10 @@ package fire
 import (
 	"bytes"
 	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
 )
 
 // String creates a string value
@@ -21,7 +25,7 @@ func (s stringValue) Code(ctx context.Context) string {
 	var buf bytes.Buffer
 	check(buf.WriteRune('"'))
 	for _, r := range string(s) {
-		if r == '"' {
+		if r == '"' || r == '\\' {
 			check(buf.WriteRune('\\'))
 		}
 		check(buf.WriteRune(r))
@@ -35,12 +39,39 @@ func (s stringValue) HashCode() interface{} {
 }
 
 func (s stringValue) Call(ctx context.Context, args ...Value) Value {
-	return errorValue("cannot call a string")
+	return newError("cannot call a string")
 }
 
+// Lookup supports the `length` property and the string methods
 func (s stringValue) Lookup(ctx context.Context, field Value) Value {
-	// TODO: add string methods
-	return errorValue("cannot lookup a string")
+	name, _ := field.String(ctx)
+	switch name {
+	case "length":
+		return numberValue(utf8.RuneCountInString(string(s)))
+	case "lower":
+		return method(s, name, s.lower)
+	case "upper":
+		return method(s, name, s.upper)
+	case "trim":
+		return method(s, name, s.trim)
+	case "startsWith":
+		return method(s, name, s.startsWith)
+	case "endsWith":
+		return method(s, name, s.endsWith)
+	case "contains":
+		return method(s, name, s.contains)
+	case "split":
+		return method(s, name, s.split)
+	case "slice":
+		return method(s, name, s.slice)
+	case "replace":
+		return method(s, name, s.replace)
+	case "matches":
+		return method(s, name, s.matches)
+	case "format":
+		return method(s, name, s.format)
+	}
+	return newError("cannot lookup a string")
 }
 
 func (s stringValue) Equals(ctx context.Context, other Value) bool {
@@ -62,3 +93,200 @@ func (s stringValue) Bool(ctx context.Context) (bool, bool) {
 func (s stringValue) Error(ctx context.Context) (error, bool) {
 	return nil, false
 }
+
+func (s stringValue) lower(ctx context.Context, args ...Value) Value {
+	return stringValue(strings.ToLower(string(s)))
+}
+
+func (s stringValue) upper(ctx context.Context, args ...Value) Value {
+	return stringValue(strings.ToUpper(string(s)))
+}
+
+func (s stringValue) trim(ctx context.Context, args ...Value) Value {
+	return stringValue(strings.TrimSpace(string(s)))
+}
+
+func (s stringValue) startsWith(ctx context.Context, args ...Value) Value {
+	prefix, err := stringArg(ctx, args, "prefix")
+	return checkError(boolValue(strings.HasPrefix(string(s), prefix)), err)
+}
+
+func (s stringValue) endsWith(ctx context.Context, args ...Value) Value {
+	suffix, err := stringArg(ctx, args, "suffix")
+	return checkError(boolValue(strings.HasSuffix(string(s), suffix)), err)
+}
+
+func (s stringValue) contains(ctx context.Context, args ...Value) Value {
+	substr, err := stringArg(ctx, args, "substring")
+	return checkError(boolValue(strings.Contains(string(s), substr)), err)
+}
+
+func (s stringValue) split(ctx context.Context, args ...Value) Value {
+	sep, err := stringArg(ctx, args, "separator")
+	if err != nil {
+		return err
+	}
+	parts := strings.Split(string(s), sep)
+	result := make(listValue, len(parts))
+	for kk, part := range parts {
+		result[kk] = stringValue(part)
+	}
+	return result
+}
+
+// slice returns the characters from start upto (but not including)
+// end.  Both are optional and are clamped to the string.
+//
+//      "hello".slice(1)
+//      "hello".slice(start = 1, end = 3)
+func (s stringValue) slice(ctx context.Context, args ...Value) Value {
+	runes := []rune(string(s))
+	start, end := 0, len(runes)
+
+	if len(args) == 1 {
+		if f, ok := args[0].Number(ctx); ok {
+			args = []Value{obj{stringValue("start"): numberValue(f)}}
+		}
+	}
+	for _, name := range []string{"start", "end"} {
+		v, ok := namedArg(args, name)
+		if !ok {
+			continue
+		}
+		f, ok := v.Number(ctx)
+		if !ok {
+			return newError(name + " must be a number")
+		}
+		if name == "start" {
+			start = int(f)
+		} else {
+			end = int(f)
+		}
+	}
+
+	if end > len(runes) {
+		end = len(runes)
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > end {
+		return stringValue("")
+	}
+	return stringValue(string(runes[start:end]))
+}
+
+// replace replaces all occurrences of old with new:
+//
+//      "a-b-c".replace(old = "-", new = "+")
+func (s stringValue) replace(ctx context.Context, args ...Value) Value {
+	var pair [2]string
+	for kk, name := range []string{"old", "new"} {
+		v, ok := namedArg(args, name)
+		if !ok {
+			return newError("missing " + name)
+		}
+		if pair[kk], ok = v.String(ctx); !ok {
+			return newError(name + " must be a string")
+		}
+	}
+	return stringValue(strings.Replace(string(s), pair[0], pair[1], -1))
+}
+
+// matches checks if the string matches a regular expression.  The
+// expression is not anchored: use ^ and $ to match the whole string.
+func (s stringValue) matches(ctx context.Context, args ...Value) Value {
+	pattern, err := stringArg(ctx, args, "pattern")
+	if err != nil {
+		return err
+	}
+	re, errx := compile(pattern)
+	if errx != nil {
+		return newError(errx.Error())
+	}
+	return boolValue(re.MatchString(string(s)))
+}
+
+// format replaces `{name}` with the value of the named arg.  String
+// values are inserted as is while other values use their code.  Use
+// `{{` and `}}` for literal braces.
+//
+//      "hello {user}".format(user = it.name)
+func (s stringValue) format(ctx context.Context, args ...Value) Value {
+	var buf bytes.Buffer
+	str := string(s)
+	for len(str) > 0 {
+		switch {
+		case strings.HasPrefix(str, "{{"), strings.HasPrefix(str, "}}"):
+			buf.WriteByte(str[0])
+			str = str[2:]
+		case str[0] == '{':
+			end := strings.IndexByte(str, '}')
+			if end < 0 {
+				return newError("format: unclosed {")
+			}
+			name := str[1:end]
+			v, ok := namedArg(args, name)
+			if !ok {
+				return newError("format: missing " + name)
+			}
+			if _, ok := v.Error(ctx); ok {
+				return v
+			}
+			if vs, ok := v.String(ctx); ok {
+				buf.WriteString(vs)
+			} else {
+				buf.WriteString(v.Code(ctx))
+			}
+			str = str[end+1:]
+		default:
+			buf.WriteByte(str[0])
+			str = str[1:]
+		}
+	}
+	return stringValue(buf.String())
+}
+
+// stringArg fetches a required string argument of a method
+func stringArg(ctx context.Context, args []Value, name string) (string, Value) {
+	v, ok := methodArg(args, name)
+	if !ok {
+		return "", newError("missing " + name)
+	}
+	if _, ok := v.Error(ctx); ok {
+		return "", v
+	}
+	s, ok := v.String(ctx)
+	if !ok {
+		return "", newError(name + " must be a string")
+	}
+	return s, nil
+}
+
+// compile compiles regular expressions, caching the results.  The
+// cache is cleared when it gets too big as the patterns could come
+// from the args of the evaluation.
+func compile(pattern string) (*regexp.Regexp, error) {
+	regexps.Lock()
+	defer regexps.Unlock()
+
+	if re, ok := regexps.cache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(regexps.cache) >= maxRegexps {
+		regexps.cache = map[string]*regexp.Regexp{}
+	}
+	regexps.cache[pattern] = re
+	return re, nil
+}
+
+const maxRegexps = 1000
+
+var regexps = struct {
+	sync.Mutex
+	cache map[string]*regexp.Regexp
+}{cache: map[string]*regexp.Regexp{}}
@@ -0,0 +1,66 @@
+package fire
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+)
+
+// stringsObject is the `strings` global
+func stringsObject(code func(c string) func(ctx context.Context) string) Value {
+	return Object(map[Value]Value{
+		String("hash"):            methodFunction(code("strings.hash"), hash),
+		String("compareVersions"): builtinFn{"strings.compareVersions", []string{"x", "y"}, compareVersions},
+	})
+}
+
+// hash maps a value to a number in [0, 1) in a stable way.  This is
+// meant for percentage rollouts: `strings.hash(it.email) < 0.1`.
+//
+// The value can be a string or a number.  Numbers are hashed as the
+// shortest decimal which round trips, without an exponent, so 42 is
+// hashed as "42" and 1e6 as "1000000".  The optional seed makes the
+// hash independent for different flags:
+//
+//      strings.hash(value = it.email, seed = "new-ui")
+//
+// The algorithm is part of the language spec and every client must
+// implement it exactly:
+//
+//      input = value if seed is empty else seed + "\x00" + value
+//      digest = SHA-256(UTF-8 bytes of input)
+//      n = first 8 bytes of digest as a big-endian uint64
+//      result = (n >> 11) / 2^53
+func hash(ctx context.Context, args ...Value) Value {
+	v, ok := methodArg(args, "value")
+	if !ok {
+		return newError("missing value")
+	}
+	if _, ok := v.Error(ctx); ok {
+		return v
+	}
+
+	input, ok := v.String(ctx)
+	if !ok {
+		f, ok := v.Number(ctx)
+		if !ok {
+			return newError("hash requires a string or a number")
+		}
+		input = strconv.FormatFloat(f, 'f', -1, 64)
+	}
+
+	if seed, ok := namedArg(args, "seed"); ok {
+		s, ok := seed.String(ctx)
+		if !ok {
+			return newError("seed must be a string")
+		}
+		if s != "" {
+			input = s + "\x00" + input
+		}
+	}
+
+	digest := sha256.Sum256([]byte(input))
+	n := binary.BigEndian.Uint64(digest[:8]) >> 11
+	return numberValue(float64(n) / (1 << 53))
+}
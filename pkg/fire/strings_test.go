@@ -0,0 +1,59 @@
+package fire_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestStringsHash(t *testing.T) {
+	ctx := context.Background()
+
+	// these values are part of the spec and must match all clients
+	suite := map[string]fire.Value{
+		`strings.hash("boo@example.com")`:                          fire.Number(0.5501244346307309),
+		`strings.hash(value = "boo@example.com")`:                  fire.Number(0.5501244346307309),
+		`strings.hash(value = "boo@example.com", seed = "")`:       fire.Number(0.5501244346307309),
+		`strings.hash(value = "boo@example.com", seed = "new-ui")`: fire.Number(0.9185793461024478),
+		`strings.hash(42)`:                                   fire.Number(0.45030764956967506),
+		`strings.hash("42") == strings.hash(42)`:             fire.Bool(true),
+		`strings.hash("1234567") == strings.hash(1234567)`:   fire.Bool(true),
+		`strings.hash("0.000001") == strings.hash(0.000001)`: fire.Bool(true),
+		`strings.hash("-2.5") == strings.hash(0 - 2.5)`:      fire.Bool(true),
+		`strings.hash(true)`:                                 fire.Error("hash requires a string or a number"),
+		`strings.hash(value = "boo", seed = 5)`:              fire.Error("seed must be a string"),
+		`strings.hash("boo@example.com") < 0.1`:              fire.Bool(false),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+}
+
+func TestStringsHashDistribution(t *testing.T) {
+	ctx := context.Background()
+	parsed, _ := parse.String(`strings.hash(value = it, seed = "rollout")`)
+
+	buckets := make([]int, 10)
+	for kk := 0; kk < 10000; kk++ {
+		scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("it"), fire.Number(float64(kk))})
+		f, ok := fire.Eval(ctx, parsed, scope).Number(ctx)
+		if !ok || f < 0 || f >= 1 {
+			t.Fatal("Unexpected hash", f)
+		}
+		buckets[int(f*10)]++
+	}
+	for _, count := range buckets {
+		if count < 900 || count > 1100 {
+			t.Fatal("Unexpected distribution", buckets)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package fire
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithClock returns a context whose `time.now()` is provided by the
+// clock function.  This is meant for tests and for evaluating
+// scheduled config at a specific time.
+func WithClock(ctx context.Context, now func() time.Time) context.Context {
+	return context.WithValue(ctx, clockKey{}, now)
+}
+
+type clockKey struct{}
+
+func now(ctx context.Context) time.Time {
+	if now, ok := ctx.Value(clockKey{}).(func() time.Time); ok {
+		return now()
+	}
+	return time.Now()
+}
+
+// Time converts a Go time into a time value (in UTC)
+func Time(t time.Time) Value {
+	return timeValue{t.UTC()}
+}
+
+// timeValue is a point in time.  Its number is the unix time in
+// seconds, so times can be compared and subtracted (the difference
+// being in seconds).
+type timeValue struct {
+	t time.Time
+}
+
+func (t timeValue) Code(ctx context.Context) string {
+	return "time.parse(" + stringValue(t.t.Format(time.RFC3339Nano)).Code(ctx) + ")"
+}
+
+func (t timeValue) HashCode() interface{} {
+	return numberValue(t.unix())
+}
+
+func (t timeValue) Call(ctx context.Context, args ...Value) Value {
+	return newError("cannot call a time")
+}
+
+// Lookup supports the date fields (in the time zone of the value,
+// which is UTC unless changed by `in`) and the time methods
+func (t timeValue) Lookup(ctx context.Context, field Value) Value {
+	name, _ := field.String(ctx)
+	switch name {
+	case "year":
+		return numberValue(t.t.Year())
+	case "month":
+		return numberValue(t.t.Month())
+	case "day":
+		return numberValue(t.t.Day())
+	case "hour":
+		return numberValue(t.t.Hour())
+	case "minute":
+		return numberValue(t.t.Minute())
+	case "second":
+		return numberValue(t.t.Second())
+	case "weekday":
+		return stringValue(t.t.Weekday().String())
+	case "unix":
+		return numberValue(t.unix())
+	case "in":
+		return method(t, name, t.in)
+	case "add":
+		return method(t, name, t.add)
+	}
+	return newError("field not found: " + field.Code(ctx))
+}
+
+func (t timeValue) Equals(ctx context.Context, other Value) bool {
+	o, ok := other.(timeValue)
+	return ok && o.t.Equal(t.t)
+}
+
+func (t timeValue) Number(ctx context.Context) (float64, bool) {
+	return t.unix(), true
+}
+
+func (t timeValue) String(ctx context.Context) (string, bool) {
+	return "", false
+}
+
+func (t timeValue) Bool(ctx context.Context) (bool, bool) {
+	return false, false
+}
+
+func (t timeValue) Error(ctx context.Context) (error, bool) {
+	return nil, false
+}
+
+func (t timeValue) unix() float64 {
+	return float64(t.t.UnixNano()) / 1e9
+}
+
+// in converts the time into the named time zone:
+//
+//      time.now().in("America/New_York").hour
+func (t timeValue) in(ctx context.Context, args ...Value) Value {
+	zone, err := stringArg(ctx, args, "zone")
+	if err != nil {
+		return err
+	}
+	loc, errx := loadLocation(zone)
+	if errx != nil {
+		return newError("unknown time zone: " + zone)
+	}
+	return timeValue{t.t.In(loc)}
+}
+
+// add adds a duration in seconds
+func (t timeValue) add(ctx context.Context, args ...Value) Value {
+	v, ok := methodArg(args, "seconds")
+	if !ok {
+		return newError("missing seconds")
+	}
+	f, ok := v.Number(ctx)
+	if !ok {
+		return newError("seconds must be a number")
+	}
+	return timeValue{t.t.Add(time.Duration(f * float64(time.Second)))}
+}
+
+// loadLocation loads time zones, caching the results
+func loadLocation(zone string) (*time.Location, error) {
+	if loc, ok := locations.Load(zone); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(zone, loc)
+	return loc, nil
+}
+
+var locations sync.Map
+
+// timeObject is the `time` global.  Durations are numbers in seconds.
+func timeObject() Value {
+	return Object(map[Value]Value{
+		String("second"): Number(1),
+		String("minute"): Number(60),
+		String("hour"):   Number(60 * 60),
+		String("day"):    Number(24 * 60 * 60),
+		String("week"):   Number(7 * 24 * 60 * 60),
+		String("now"): builtinFn{"time.now", []string{}, func(ctx context.Context, args []Value) Value {
+			return timeValue{now(ctx).UTC()}
+		}},
+		String("parse"): builtinFn{"time.parse", []string{"it"}, func(ctx context.Context, args []Value) Value {
+			return toTime(ctx, args[0])
+		}},
+		String("unix"): numeric("time.unix", []string{"seconds"}, func(f []float64) Value {
+			return timeValue{time.Unix(0, int64(f[0]*1e9)).UTC()}
+		}),
+		String("duration"): builtinFn{"time.duration", []string{"it"}, func(ctx context.Context, args []Value) Value {
+			s, ok := args[0].String(ctx)
+			if !ok {
+				return newError("time.duration requires a string")
+			}
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return newError("time.duration: invalid duration " + args[0].Code(ctx))
+			}
+			return numberValue(d.Seconds())
+		}},
+		String("ramp"): builtinFn{"time.ramp", []string{"start", "end"}, ramp},
+	})
+}
+
+// toTime converts times and strings into times.  Strings can be
+// RFC3339 timestamps or dates (which are midnight UTC).
+func toTime(ctx context.Context, v Value) Value {
+	if _, ok := v.Error(ctx); ok {
+		return v
+	}
+	if t, ok := v.(timeValue); ok {
+		return t
+	}
+	s, ok := v.String(ctx)
+	if !ok {
+		return newError("not a time")
+	}
+	layout := time.RFC3339Nano
+	if !strings.Contains(s, "T") {
+		layout = "2006-01-02"
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return newError("invalid time " + v.Code(ctx))
+	}
+	return timeValue{t.UTC()}
+}
+
+// ramp goes linearly from 0 at the start time to 1 at the end time.
+// It is meant for gradual rollouts:
+//
+//      strings.hash(it.id) < time.ramp(start = "2019-10-01", end = "2019-10-08")
+func ramp(ctx context.Context, args []Value) Value {
+	start, end := toTime(ctx, args[0]), toTime(ctx, args[1])
+	for _, v := range []Value{start, end} {
+		if _, ok := v.Error(ctx); ok {
+			return v
+		}
+	}
+
+	s, e := start.(timeValue).t, end.(timeValue).t
+	if !e.After(s) {
+		return newError("time.ramp: end must be after start")
+	}
+	n := now(ctx)
+	switch {
+	case n.Before(s):
+		return numberValue(0)
+	case n.After(e):
+		return numberValue(1)
+	}
+	return numberValue(float64(n.Sub(s)) / float64(e.Sub(s)))
+}
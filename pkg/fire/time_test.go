@@ -0,0 +1,70 @@
+package fire_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestTime(t *testing.T) {
+	// Monday 2019-10-07 14:30 UTC
+	pinned := time.Date(2019, 10, 7, 14, 30, 0, 0, time.UTC)
+	ctx := fire.WithClock(context.Background(), func() time.Time { return pinned })
+	n, s, b := fire.Number, fire.String, fire.Bool
+
+	suite := map[string]fire.Value{
+		`time.now()`:                                          fire.Time(pinned),
+		`time.now().weekday`:                                  s("Monday"),
+		`time.now().hour`:                                     n(14),
+		`time.now().in("America/New_York").hour`:              n(10),
+		`time.now().in(zone = "Asia/Kolkata").minute`:         n(0),
+		`time.now().in("Nowhere/Land")`:                       fire.Error("unknown time zone: Nowhere/Land"),
+		`time.now() > time.parse("2019-10-07T09:00:00Z")`:     b(true),
+		`time.now() < time.parse("2019-10-07")`:               b(false),
+		`time.now() - time.parse("2019-10-07")`:               n(14*3600 + 30*60),
+		`time.parse("2019-10-07").add(time.day).day`:          n(8),
+		`time.parse("2019-10-07") == time.unix(1570406400)`:   b(true),
+		`time.parse("2019-10-07").unix`:                       n(1570406400),
+		`time.parse("2019-10-07").year`:                       n(2019),
+		`time.parse("2019-10-07").month`:                      n(10),
+		`time.parse("2019-10-07T09:00:00+02:00").hour`:        n(7),
+		`time.parse("yesterday")`:                             fire.Error(`invalid time "yesterday"`),
+		`time.duration("1h30m")`:                              n(5400),
+		`time.duration("soon")`:                               fire.Error(`time.duration: invalid duration "soon"`),
+		`time.hour * 2`:                                       n(7200),
+		`time.ramp(start = "2019-10-07", end = "2019-10-09")`: n(14.5 / 48),
+		`time.ramp("2019-10-08", "2019-10-09")`:               n(0),
+		`time.ramp("2019-10-01", "2019-10-02")`:               n(1),
+		`time.ramp("2019-10-02", "2019-10-01")`:               fire.Error("time.ramp: end must be after start"),
+	}
+
+	for k, v := range suite {
+		parsed, errs := parse.String(k)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", k, errs)
+		}
+		if got := fire.Eval(ctx, parsed, fire.Globals()); !got.Equals(ctx, v) {
+			t.Error("Mismatched values", k, v, got)
+		}
+	}
+
+	v := fire.Time(pinned)
+	if code := v.Code(ctx); code != `time.parse("2019-10-07T14:30:00Z")` {
+		t.Error("Unexpected code", code)
+	}
+	if native := fire.ToNative(ctx, v); native != pinned {
+		t.Error("Unexpected native", native)
+	}
+
+	// Go times are converted to UTC
+	paris := time.FixedZone("CEST", 2*3600)
+	local := time.Date(2019, 10, 7, 9, 0, 0, 0, paris)
+	for _, v := range []fire.Value{fire.Time(local), fire.FromNative(ctx, local)} {
+		if hour := v.Lookup(ctx, s("hour")); !hour.Equals(ctx, n(7)) {
+			t.Error("Unexpected hour", hour)
+		}
+	}
+}
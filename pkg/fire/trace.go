@@ -0,0 +1,60 @@
+package fire
+
+import "context"
+
+// Step is a single sub-expression evaluated during a traced Eval.
+//
+// Start and End are the source offsets from the list expression
+// head (for operators and calls, these point to the operator or the
+// opening paren).  Depth is the nesting level of the sub-expression
+// with the outermost expression at zero.
+type Step struct {
+	Op         string
+	Start, End int
+	Depth      int
+	Result     Value
+}
+
+// Trace holds the steps recorded by Eval.  See WithTrace.
+type Trace struct {
+	Steps []Step
+	depth int
+}
+
+// WithTrace returns a context which causes Eval to record every
+// sub-expression it evaluates (other than literals) into the
+// returned trace.  The steps are in the order the evaluation started,
+// so the outer expressions come before the inner ones.
+//
+// This is meant for explaining why an expression evaluated to a
+// specific value.  For instance, the steps show which branch of an
+// `if` was taken.
+func WithTrace(ctx context.Context) (context.Context, *Trace) {
+	t := &Trace{}
+	return context.WithValue(ctx, traceKey{}, t), t
+}
+
+type traceKey struct{}
+
+// eval is like builtin except it also records the step
+func (t *Trace) eval(ctx context.Context, parts []string, args []interface{}, scope Value) Value {
+	switch parts[0] {
+	case "string", "number", "bool":
+		return builtin(ctx, parts[0], args, scope)
+	}
+
+	step := Step{Op: parts[0], Depth: t.depth}
+	if len(parts) == 3 {
+		loc := location(parts)
+		step.Start, step.End = loc.Start, loc.End
+	}
+	idx := len(t.Steps)
+	t.Steps = append(t.Steps, step)
+
+	t.depth++
+	result := builtin(ctx, parts[0], args, scope)
+	t.depth--
+
+	t.Steps[idx].Result = result
+	return result
+}
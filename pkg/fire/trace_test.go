@@ -0,0 +1,52 @@
+package fire_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestTrace(t *testing.T) {
+	ctx, trace := fire.WithTrace(context.Background())
+	it := fire.FromNative(ctx, map[string]interface{}{"user": "boo"})
+	scope := fire.Scope(ctx, fire.Globals(), [2]fire.Value{fire.String("it"), it})
+
+	parsed, errs := parse.String(`if(it.user == "boo", it.age, 5)`)
+	if len(errs) > 0 {
+		t.Fatal("Unexpected parse error", errs)
+	}
+	result := fire.Eval(ctx, parsed, scope)
+	if !result.Equals(ctx, fire.Error(`field not found: "age"`)) {
+		t.Fatal("Unexpected result", result)
+	}
+
+	expected := []struct {
+		op         string
+		start, end int
+		depth      int
+		result     fire.Value
+	}{
+		{"call", 2, 2, 0, result},
+		{"name", 0, 2, 1, nil},
+		{"==", 11, 13, 1, fire.Bool(true)},
+		{".", 5, 6, 2, fire.String("boo")},
+		{"name", 3, 5, 3, it},
+		{".", 23, 24, 1, result},
+		{"name", 21, 23, 2, it},
+	}
+
+	if len(trace.Steps) != len(expected) {
+		t.Fatal("Unexpected steps", trace.Steps)
+	}
+	for kk, step := range trace.Steps {
+		e := expected[kk]
+		if step.Op != e.op || step.Start != e.start || step.End != e.end || step.Depth != e.depth {
+			t.Error("Unexpected step", kk, step)
+		}
+		if e.result != nil && !step.Result.Equals(ctx, e.result) {
+			t.Error("Unexpected step result", kk, step.Result)
+		}
+	}
+}
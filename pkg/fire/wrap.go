@@ -2,10 +2,21 @@ package fire
 
 import (
 	"context"
+	"reflect"
 )
 
 // ToNative unwraps a value into native Go types
+//
+// Lists are returned as []interface{} and times as time.Time.
+// Values created by FromNative from structs, slices or maps are
+// returned as the original Go value.
 func ToNative(ctx context.Context, v Value) interface{} {
+	if s, ok := v.(*stream); ok {
+		return ToNative(ctx, s.current(ctx))
+	}
+	if t, ok := v.(timeValue); ok {
+		return t.t
+	}
 	if s, ok := v.String(ctx); ok {
 		return s
 	}
@@ -25,18 +36,42 @@ func ToNative(ctx context.Context, v Value) interface{} {
 		}
 		return result
 	}
+	if l, ok := v.(listValue); ok {
+		result := make([]interface{}, len(l))
+		for kk, elt := range l {
+			result[kk] = ToNative(ctx, elt)
+		}
+		return result
+	}
+	if n, ok := v.(nativeValue); ok && n.rv.CanInterface() {
+		return n.rv.Interface()
+	}
 	return nil
 }
 
 // FromNative wraps an interface into a Value
+//
+// Besides the basic JSON-like types, any Go value is accepted: all
+// int and uint kinds are converted to numbers, time.Time to a time
+// value and pointers are followed.  Structs, slices and maps with
+// string keys are not copied.  Instead, their fields are converted
+// only when looked up.  Slices behave like lists.  Struct field
+// names follow the encoding/json conventions (including json tags).
 func FromNative(ctx context.Context, v interface{}) Value {
 	switch v := v.(type) {
+	case Value:
+		return v
 	case string:
 		return String(v)
 	case float64:
 		return Number(v)
 	case bool:
 		return Bool(v)
+	case *EvalError:
+		if v.Location == nil {
+			return Error(v.Message)
+		}
+		return errorValue{v.Message, v}
 	case error:
 		return Error(v.Error())
 	case map[interface{}]interface{}:
@@ -46,5 +81,5 @@ func FromNative(ctx context.Context, v interface{}) Value {
 		}
 		return Object(result)
 	}
-	return Error("unknown native type")
+	return fromReflect(reflect.ValueOf(v))
 }
@@ -0,0 +1,126 @@
+package parse
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Format converts a parsed expression back into code.
+//
+// The result parses back into the same expression except for
+// the source offsets.  Parentheses are only added where needed and
+// whitespace is normalized.
+func Format(v interface{}) string {
+	s, _ := format(v)
+	return s
+}
+
+// termPriority is the priority of terms which never need parens
+const termPriority = 50
+
+// format returns the code as well as the priority of the top-level
+// operator
+func format(v interface{}) (string, int) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) == 0 {
+		return "", termPriority
+	}
+	op := strings.Split(list[0].(string), ":")[0]
+	args := list[1:]
+
+	switch op {
+	case "string":
+		return quote(args[0].(string)), termPriority
+	case "number":
+		return strconv.FormatFloat(args[0].(float64), 'f', -1, 64), termPriority
+	case "bool":
+		return strconv.FormatBool(args[0].(bool)), termPriority
+	case "name":
+		return args[0].(string), termPriority
+	case "call":
+		fn := wrap(args[0], priority["."])
+		return fn + "(" + formatArgs(args[1:]) + ")", termPriority
+	case "{}":
+		return "{" + formatArgs(args) + "}", termPriority
+	case ".":
+		left := wrap(args[0], priority["."])
+		if field, ok := fieldName(args[1]); ok {
+			return left + "." + field, priority["."]
+		}
+		right, _ := format(args[1])
+		return left + ".(" + right + ")", priority["."]
+	case "=":
+		name, _ := args[0].([]interface{})[1].(string)
+		value, _ := format(args[1])
+		return Name(name) + " = " + value, priority["="]
+	}
+
+	pri := priority[op]
+	if len(args) == 1 {
+		// unary operators bind looser than binary ones of the same
+		// priority: -(x + y) needs parens but -(x * y) doesn't.
+		// The exception is ! which is right associative.
+		if op == "!" {
+			return op + wrap(args[0], pri), pri
+		}
+		return op + wrap(args[0], pri+1), pri
+	}
+	return wrap(args[0], pri) + " " + op + " " + wrap(args[1], pri+1), pri
+}
+
+// wrap formats the expression, adding parens if its priority is
+// less than the provided one
+func wrap(v interface{}, pri int) string {
+	s, p := format(v)
+	if p < pri {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func formatArgs(args []interface{}) string {
+	result := make([]string, len(args))
+	for kk, arg := range args {
+		result[kk], _ = format(arg)
+	}
+	return strings.Join(result, ", ")
+}
+
+// Name formats the name on the left of `name = value`.  It is quoted
+// unless it is a valid identifier.
+func Name(s string) string {
+	if isName(s) {
+		return s
+	}
+	return quote(s)
+}
+
+// fieldName returns the field if the expression is a string that can
+// be written as `x.field`
+func fieldName(v interface{}) (string, bool) {
+	list, ok := v.([]interface{})
+	if !ok || len(list) != 2 || !strings.HasPrefix(list[0].(string), "string") {
+		return "", false
+	}
+	s, _ := list[1].(string)
+	return s, isName(s)
+}
+
+func isName(s string) bool {
+	if x := strings.ToLower(s); x == "" || x == "true" || x == "false" {
+		return false
+	}
+	for kk, r := range s {
+		if !unicode.IsLetter(r) && (kk == 0 || !unicode.IsDigit(r) && r != '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// quote is the inverse of the unquoting done by the tokenizer
+func quote(s string) string {
+	s = strings.Replace(s, `\`, `\\`, -1)
+	return `"` + strings.Replace(s, `"`, `\"`, -1) + `"`
+}
@@ -0,0 +1,74 @@
+package parse_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/parse"
+)
+
+func TestFormat(t *testing.T) {
+	cases := map[string]string{
+		"x + y + z":                         "x + y + z",
+		"x + (y + z)":                       "x + (y + z)",
+		"(x + y) * z":                       "(x + y) * z",
+		"x*y+z":                             "x * y + z",
+		"f(x = y = z, a = b)":               "f(x = y = z, a = b)",
+		`"hel\"lo".length`:                  `"hel\"lo".length`,
+		`"a\\b"`:                            `"a\\b"`,
+		"f()":                               "f()",
+		"f.g().h(5).k":                      "f.g().h(5).k",
+		"f(x = g(), where (g = h))":         "f(x = g(), where(g = h))",
+		`f.g(x + y*3.2,"hell\"o").h + 1000`: `f.g(x + y * 3.2, "hell\"o").h + 1000`,
+		"!(x == y)":                         "!(x == y)",
+		"!(-1 < +x)":                        "!(-1 < +x)",
+		"-(x + y)":                          "-(x + y)",
+		"-x * y":                            "-x * y",
+		"(-x) * y":                          "(-x) * y",
+		"x < y & y < z | boo":               "x < y & y < z | boo",
+		"!!x":                               "!!x",
+		"x.(y)":                             "x.(y)",
+		`x.("a b")`:                         `x.("a b")`,
+		`x.("true")`:                        `x.("true")`,
+		"{ x, y = 23 }":                     "{x, y = 23}",
+		`f("user-id" = 1, "x" = 2)`:         `f("user-id" = 1, x = 2)`,
+		"f({ z.x, z = g() })":               "f({z.x, z = g()})",
+		"{x}()":                             "{x}()",
+		"(f + g)(x)":                        "(f + g)(x)",
+		"x % 2 == 0":                        "x % 2 == 0",
+		"1000000000000000000000":            "1000000000000000000000",
+		"true & false":                      "true & false",
+	}
+
+	for code, expected := range cases {
+		parsed, errs := parse.String(code)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", code, errs)
+		}
+		formatted := parse.Format(parsed)
+		if formatted != expected {
+			t.Error("Unexpected format", code, formatted)
+		}
+
+		reparsed, errs := parse.String(formatted)
+		if len(errs) > 0 {
+			t.Fatal("Unexpected parse error", formatted, errs)
+		}
+		if !reflect.DeepEqual(stripOffsets(parsed), stripOffsets(reparsed)) {
+			t.Error("Format did not round trip", code, formatted)
+		}
+	}
+}
+
+func stripOffsets(v interface{}) interface{} {
+	list, ok := v.([]interface{})
+	if !ok {
+		return v
+	}
+	result := []interface{}{strings.Split(list[0].(string), ":")[0]}
+	for _, elt := range list[1:] {
+		result = append(result, stripOffsets(elt))
+	}
+	return result
+}
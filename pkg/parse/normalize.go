@@ -103,5 +103,10 @@ func normalizeScopeLHS(v interface{}, errs *[]error) interface{} {
 		return normalize(v, errs)
 	}
 
+	// quoted names allow any string: "user-id" = 5
+	if n, ok := v.([]interface{}); ok && n[0] == "string" {
+		return normalize(v, errs)
+	}
+
 	panic("NYI") // allow .x type expressions too but nothing else
 }
@@ -29,6 +29,7 @@ var priority = map[string]int{
 	"-":  10,
 	"*":  20,
 	"/":  20,
+	"%":  20,
 	"!":  30,
 	".":  40,
 }
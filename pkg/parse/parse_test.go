@@ -13,6 +13,7 @@ func TestSuite(t *testing.T) {
 		"x + y + z",
 		"f(x = y = z, a = b)",
 		`"hel\"lo".length`,
+		`"a\\" + "\\\"b"`,
 		"f()",
 		"f.g().h(5).k",
 		"f(x = g(), where (g = h))",
@@ -25,6 +26,7 @@ func TestSuite(t *testing.T) {
 		"{ x, y = 23 }",
 		"f({ z.x, z = g() })",
 		"{x}()",
+		"x % y * z + 1",
 
 		// error cases
 		"x + + y",
@@ -37,6 +39,7 @@ func TestSuite(t *testing.T) {
 		"true",
 		"false",
 		"f(x = y)",
+		`"a\\\"`,
 	}
 
 	results := map[string]interface{}{}
@@ -34,13 +34,13 @@ func (t *tokenizer) next(r rune, offset int, last bool, errs *[]error) (string,
 
 func (t *tokenizer) quote(offset int, last bool, errs *[]error) (string, int, int, bool) {
 	l := len(t.seen)
-	if l == 1 || (l > 1 && t.seen[l-1] != '"') || (l > 2 && t.seen[l-2] == '\\') {
+	if l == 1 || t.seen[l-1] != '"' || escaped(t.seen[1:l-1]) {
 		if !last {
 			return "", -1, -1, false
 		}
 		// incomplete
 		*errs = append(*errs, IncompleteStringError(t.start))
-		if t.seen[l-1] == '\\' {
+		if escaped(t.seen[1:]) {
 			t.seen = append(t.seen, '\\')
 		}
 		t.seen = append(t.seen, '"')
@@ -51,6 +51,16 @@ func (t *tokenizer) quote(offset int, last bool, errs *[]error) (string, int, in
 	return result, t.start, t.start + len(result), true
 }
 
+// escaped checks if the string ends with an unpaired backslash and so
+// escapes whatever follows
+func escaped(rs []rune) bool {
+	count := 0
+	for kk := len(rs) - 1; kk >= 0 && rs[kk] == '\\'; kk-- {
+		count++
+	}
+	return count%2 == 1
+}
+
 func (t *tokenizer) number(offset int, last bool, errs *[]error) (string, int, int, bool) {
 	_, err := strconv.ParseFloat(string(t.seen), 64)
 	if err == nil {
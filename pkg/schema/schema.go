@@ -0,0 +1,459 @@
+// Package schema implements JSON schemas for the results of config
+// entries.
+//
+// The schema for a key is stored as a JSON object in the entry for
+// Key(key).  The server rejects changes to the key whose results do
+// not match the schema and the fig client checks the results in
+// Decode.
+//
+// The following subset of JSON Schema is supported:
+//
+//      type (including "integer" and lists of types), enum, const
+//      minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+//      minLength, maxLength, pattern
+//      items, minItems, maxItems, uniqueItems
+//      properties, required, additionalProperties
+//      allOf, anyOf, oneOf, not
+//
+// Other keywords are ignored.  The non-standard "inputs" keyword
+// lists sample args used by the server to check the entry:
+//
+//      {"type": "number", "maximum": 1, "inputs": [{"beta": true}, {}]}
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Prefix is the reserved prefix of keys holding schemas
+const Prefix = "schema:"
+
+// Key returns the key of the schema for a config entry
+func Key(key string) string {
+	return Prefix + key
+}
+
+// Schema is a parsed JSON schema
+type Schema struct {
+	// Inputs are the sample args for the config entry
+	Inputs []interface{}
+
+	root interface{}
+}
+
+// Parse parses a JSON schema.  Schemas must be JSON objects.
+func Parse(source string) (*Schema, error) {
+	var root interface{}
+	if err := json.Unmarshal([]byte(source), &root); err != nil {
+		return nil, err
+	}
+	o, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("schema must be an object")
+	}
+	if err := validSchema(o, ""); err != nil {
+		return nil, err
+	}
+
+	s := &Schema{root: o}
+	if inputs, ok := o["inputs"]; ok {
+		if s.Inputs, ok = inputs.([]interface{}); !ok {
+			return nil, errors.New("inputs must be an array")
+		}
+	}
+	return s, nil
+}
+
+// Error is a value which does not match the schema.  The path is a
+// JSON pointer to the part of the value that failed.
+type Error struct {
+	Path    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Path == "" {
+		return e.Message
+	}
+	return e.Path + ": " + e.Message
+}
+
+// Validate checks if the value matches the schema.  The error is
+// always an *Error.
+//
+// The value is normalized first (see Normalize), so the results of
+// fire.ToNative can be validated directly.
+func (s *Schema) Validate(v interface{}) error {
+	if err := validate(s.root, Normalize(v), ""); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Normalize converts a value into the form returned by
+// encoding/json.  Objects with non-string keys are converted to use
+// string keys and all numbers become float64.
+func Normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string, float64, bool:
+		return v
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for kk, elt := range v {
+			result[kk] = Normalize(elt)
+		}
+		return result
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for k, elt := range v {
+			result[k] = Normalize(elt)
+		}
+		return result
+	case map[interface{}]interface{}:
+		result := map[string]interface{}{}
+		for k, elt := range v {
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			result[key] = Normalize(elt)
+		}
+		return result
+	}
+
+	if data, err := json.Marshal(v); err == nil {
+		var result interface{}
+		if json.Unmarshal(data, &result) == nil {
+			return result
+		}
+	}
+	return v
+}
+
+// validSchema checks the keywords which could otherwise fail
+// during validation
+func validSchema(s interface{}, path string) error {
+	if _, ok := s.(bool); ok {
+		return nil
+	}
+	o, ok := s.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s: schema must be an object or bool", path)
+	}
+
+	for _, t := range types(o) {
+		if _, ok := t.(string); !ok {
+			return fmt.Errorf("%s/type: must be a string or array of strings", path)
+		}
+	}
+	for _, name := range []string{"minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf", "minLength", "maxLength", "minItems", "maxItems"} {
+		if v, ok := o[name]; ok {
+			if _, ok := v.(float64); !ok {
+				return fmt.Errorf("%s/%s: must be a number", path, name)
+			}
+		}
+	}
+	if p, ok := o["pattern"]; ok {
+		pattern, ok := p.(string)
+		if !ok {
+			return fmt.Errorf("%s/pattern: must be a string", path)
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("%s/pattern: %v", path, err)
+		}
+	}
+	if enum, ok := o["enum"]; ok {
+		if _, ok := enum.([]interface{}); !ok {
+			return fmt.Errorf("%s/enum: must be an array", path)
+		}
+	}
+	if required, ok := o["required"]; ok {
+		names, ok := required.([]interface{})
+		for _, name := range names {
+			_, isString := name.(string)
+			ok = ok && isString
+		}
+		if !ok {
+			return fmt.Errorf("%s/required: must be an array of strings", path)
+		}
+	}
+
+	for _, name := range []string{"items", "additionalProperties", "not"} {
+		if v, ok := o[name]; ok {
+			if err := validSchema(v, path+"/"+name); err != nil {
+				return err
+			}
+		}
+	}
+	if props, ok := o["properties"]; ok {
+		m, ok := props.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s/properties: must be an object", path)
+		}
+		for k, v := range m {
+			if err := validSchema(v, path+"/properties/"+k); err != nil {
+				return err
+			}
+		}
+	}
+	for _, name := range []string{"allOf", "anyOf", "oneOf"} {
+		if v, ok := o[name]; ok {
+			list, ok := v.([]interface{})
+			if !ok || len(list) == 0 {
+				return fmt.Errorf("%s/%s: must be a non-empty array", path, name)
+			}
+			for kk, elt := range list {
+				if err := validSchema(elt, fmt.Sprintf("%s/%s/%d", path, name, kk)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func types(o map[string]interface{}) []interface{} {
+	switch t := o["type"].(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return t
+	default:
+		return []interface{}{t}
+	}
+}
+
+func validate(s, v interface{}, path string) *Error {
+	fail := func(format string, args ...interface{}) *Error {
+		return &Error{Path: path, Message: fmt.Sprintf(format, args...)}
+	}
+
+	if b, ok := s.(bool); ok {
+		if !b {
+			return fail("no value is allowed")
+		}
+		return nil
+	}
+	o := s.(map[string]interface{})
+
+	if t := types(o); t != nil {
+		matched := false
+		names := []string{}
+		for _, name := range t {
+			names = append(names, name.(string))
+			matched = matched || isType(name.(string), v)
+		}
+		if !matched {
+			return fail("expected %s, got %s", strings.Join(names, " or "), typeOf(v))
+		}
+	}
+	if c, ok := o["const"]; ok && !reflect.DeepEqual(c, v) {
+		return fail("expected %s", encode(c))
+	}
+	if enum, ok := o["enum"]; ok {
+		found := false
+		for _, e := range enum.([]interface{}) {
+			found = found || reflect.DeepEqual(e, v)
+		}
+		if !found {
+			return fail("expected one of %s", encode(enum))
+		}
+	}
+
+	switch v := v.(type) {
+	case float64:
+		if err := validateNumber(o, v, fail); err != nil {
+			return err
+		}
+	case string:
+		if err := validateString(o, v, fail); err != nil {
+			return err
+		}
+	case []interface{}:
+		if err := validateArray(o, v, path, fail); err != nil {
+			return err
+		}
+	case map[string]interface{}:
+		if err := validateObject(o, v, path, fail); err != nil {
+			return err
+		}
+	}
+
+	return validateCombinations(o, v, path, fail)
+}
+
+func validateNumber(o map[string]interface{}, v float64, fail func(string, ...interface{}) *Error) *Error {
+	if min, ok := o["minimum"].(float64); ok && v < min {
+		return fail("%v is less than %v", v, min)
+	}
+	if max, ok := o["maximum"].(float64); ok && v > max {
+		return fail("%v is more than %v", v, max)
+	}
+	if min, ok := o["exclusiveMinimum"].(float64); ok && v <= min {
+		return fail("%v is not more than %v", v, min)
+	}
+	if max, ok := o["exclusiveMaximum"].(float64); ok && v >= max {
+		return fail("%v is not less than %v", v, max)
+	}
+	if m, ok := o["multipleOf"].(float64); ok && m > 0 {
+		if q := v / m; math.Abs(q-math.Round(q)) > 1e-9 {
+			return fail("%v is not a multiple of %v", v, m)
+		}
+	}
+	return nil
+}
+
+func validateString(o map[string]interface{}, v string, fail func(string, ...interface{}) *Error) *Error {
+	length := float64(len([]rune(v)))
+	if min, ok := o["minLength"].(float64); ok && length < min {
+		return fail("shorter than %v characters", min)
+	}
+	if max, ok := o["maxLength"].(float64); ok && length > max {
+		return fail("longer than %v characters", max)
+	}
+	if pattern, ok := o["pattern"].(string); ok && !regexp.MustCompile(pattern).MatchString(v) {
+		return fail("does not match %s", encode(pattern))
+	}
+	return nil
+}
+
+func validateArray(o map[string]interface{}, v []interface{}, path string, fail func(string, ...interface{}) *Error) *Error {
+	length := float64(len(v))
+	if min, ok := o["minItems"].(float64); ok && length < min {
+		return fail("fewer than %v items", min)
+	}
+	if max, ok := o["maxItems"].(float64); ok && length > max {
+		return fail("more than %v items", max)
+	}
+	if unique, _ := o["uniqueItems"].(bool); unique {
+		for i := range v {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(v[i], v[j]) {
+					return fail("items %d and %d are the same", j, i)
+				}
+			}
+		}
+	}
+	if items, ok := o["items"]; ok {
+		for kk, elt := range v {
+			if err := validate(items, elt, fmt.Sprintf("%s/%d", path, kk)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateObject(o map[string]interface{}, v map[string]interface{}, path string, fail func(string, ...interface{}) *Error) *Error {
+	if required, ok := o["required"].([]interface{}); ok {
+		for _, name := range required {
+			if _, ok := v[name.(string)]; !ok {
+				return fail("missing %s", encode(name))
+			}
+		}
+	}
+
+	props, _ := o["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s, ok := props[k]
+		if !ok {
+			if s, ok = o["additionalProperties"]; !ok {
+				continue
+			}
+			if b, ok := s.(bool); ok && !b {
+				return fail("unexpected property %s", encode(k))
+			}
+		}
+		if err := validate(s, v[k], path+"/"+escape(k)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateCombinations(o map[string]interface{}, v interface{}, path string, fail func(string, ...interface{}) *Error) *Error {
+	if allOf, ok := o["allOf"].([]interface{}); ok {
+		for _, s := range allOf {
+			if err := validate(s, v, path); err != nil {
+				return err
+			}
+		}
+	}
+	if anyOf, ok := o["anyOf"].([]interface{}); ok {
+		var first *Error
+		for _, s := range anyOf {
+			if first = validate(s, v, path); first == nil {
+				break
+			}
+		}
+		if first != nil {
+			return fail("does not match any of the schemas in anyOf")
+		}
+	}
+	if oneOf, ok := o["oneOf"].([]interface{}); ok {
+		count := 0
+		for _, s := range oneOf {
+			if validate(s, v, path) == nil {
+				count++
+			}
+		}
+		if count != 1 {
+			return fail("matches %d of the schemas in oneOf", count)
+		}
+	}
+	if not, ok := o["not"]; ok && validate(not, v, path) == nil {
+		return fail("matches the schema in not")
+	}
+	return nil
+}
+
+func isType(name string, v interface{}) bool {
+	if name == "integer" {
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	}
+	return name == typeOf(v)
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func encode(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+// escape escapes a JSON pointer segment
+func escape(s string) string {
+	return strings.Replace(strings.Replace(s, "~", "~0", -1), "/", "~1", -1)
+}
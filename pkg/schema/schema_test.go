@@ -0,0 +1,85 @@
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/rameshvk/fig/pkg/schema"
+)
+
+func TestValidate(t *testing.T) {
+	suite := []struct {
+		schema   string
+		value    interface{}
+		expected string
+	}{
+		{`{}`, "anything", ""},
+		{`{"type": "number"}`, 1.5, ""},
+		{`{"type": "number"}`, "1.5", "expected number, got string"},
+		{`{"type": ["number", "null"]}`, nil, ""},
+		{`{"type": "integer"}`, 1.5, "expected integer, got number"},
+		{`{"type": "integer"}`, 2, ""},
+		{`{"minimum": 0, "maximum": 1}`, 2.0, "2 is more than 1"},
+		{`{"exclusiveMinimum": 0}`, 0.0, "0 is not more than 0"},
+		{`{"exclusiveMaximum": 1}`, 1.0, "1 is not less than 1"},
+		{`{"multipleOf": 0.25}`, 0.75, ""},
+		{`{"multipleOf": 0.25}`, 0.8, "0.8 is not a multiple of 0.25"},
+		{`{"minimum": 0}`, "not a number", ""},
+		{`{"enum": ["red", "green"]}`, "blue", `expected one of ["red","green"]`},
+		{`{"const": true}`, false, "expected true"},
+		{`{"minLength": 2, "maxLength": 3}`, "abcd", "longer than 3 characters"},
+		{`{"minLength": 2}`, "é", "shorter than 2 characters"},
+		{`{"pattern": "^v[0-9]+$"}`, "v12", ""},
+		{`{"pattern": "^v[0-9]+$"}`, "12", `does not match "^v[0-9]+$"`},
+		{`{"items": {"type": "string"}}`, []interface{}{"a", 1.0}, "/1: expected string, got number"},
+		{`{"minItems": 1}`, []interface{}{}, "fewer than 1 items"},
+		{`{"maxItems": 1}`, []interface{}{1.0, 2.0}, "more than 1 items"},
+		{`{"uniqueItems": true}`, []interface{}{1.0, 1.0}, "items 0 and 1 are the same"},
+		{`{"required": ["a"]}`, map[string]interface{}{}, `missing "a"`},
+		{`{"properties": {"a/b": {"type": "number"}}}`, map[interface{}]interface{}{"a/b": "x"}, "/a~1b: expected number, got string"},
+		{`{"additionalProperties": false}`, map[string]interface{}{"x": 1.0}, `unexpected property "x"`},
+		{`{"additionalProperties": {"type": "string"}}`, map[string]interface{}{"x": 1.0}, "/x: expected string, got number"},
+		{`{"allOf": [{"minimum": 0}, {"maximum": 1}]}`, -1.0, "-1 is less than 0"},
+		{`{"anyOf": [{"type": "string"}, {"type": "number"}]}`, true, "does not match any of the schemas in anyOf"},
+		{`{"oneOf": [{"minimum": 0}, {"maximum": 1}]}`, 0.5, "matches 2 of the schemas in oneOf"},
+		{`{"not": {"type": "string"}}`, "x", "matches the schema in not"},
+		{`{"properties": {"x": false}}`, map[string]interface{}{"x": 1.0}, "/x: no value is allowed"},
+	}
+
+	for _, test := range suite {
+		s, err := schema.Parse(test.schema)
+		if err != nil {
+			t.Fatal("Unexpected parse error", test.schema, err)
+		}
+		err = s.Validate(test.value)
+		if test.expected == "" && err != nil || test.expected != "" && (err == nil || err.Error() != test.expected) {
+			t.Error("Unexpected", test.schema, test.value, err)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	invalid := map[string]string{
+		`[]`:                                 "schema must be an object",
+		`{"type": 1}`:                        "/type: must be a string or array of strings",
+		`{"maximum": "1"}`:                   "/maximum: must be a number",
+		`{"pattern": "("}`:                   "/pattern: error parsing regexp: missing closing ): `(`",
+		`{"required": [1]}`:                  "/required: must be an array of strings",
+		`{"items": 1}`:                       "/items: schema must be an object or bool",
+		`{"properties": {"a": {"type": 2}}}`: "/properties/a/type: must be a string or array of strings",
+		`{"anyOf": []}`:                      "/anyOf: must be a non-empty array",
+		`{"inputs": {}}`:                     "inputs must be an array",
+	}
+	for source, expected := range invalid {
+		if _, err := schema.Parse(source); err == nil || err.Error() != expected {
+			t.Error("Unexpected", source, err)
+		}
+	}
+
+	s, err := schema.Parse(`{"type": "number", "inputs": [{"beta": true}, {}]}`)
+	if err != nil || len(s.Inputs) != 2 {
+		t.Error("Unexpected", s, err)
+	}
+	if schema.Key("x") != "schema:x" {
+		t.Error("Unexpected key", schema.Key("x"))
+	}
+}
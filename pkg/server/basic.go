@@ -4,9 +4,9 @@
 package server
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 
 	"github.com/rameshvk/fig/pkg/fire"
@@ -16,6 +16,11 @@ import (
 // BasicAuth is the basic auth middleware that checks if a request
 // is authorized by looking up the store for the key `auth:basic:user`.
 // If allowed, it uses the authoried store, else the unauthorized store
+//
+// The expression has access to the `key`, `secret`, `api` and `ip`
+// (the client address) variables:
+//
+//      secret == "xyz" & ip.inCIDR("10.0.0.0/8")
 func BasicAuth(s Store, authorized, unauthorized func(r *http.Request) Store) func(r *http.Request) Store {
 	return func(r *http.Request) Store {
 		user, pass, ok := r.BasicAuth()
@@ -29,13 +34,18 @@ func BasicAuth(s Store, authorized, unauthorized func(r *http.Request) Store) fu
 				return unauthorized(r)
 			}
 
-			ctx := context.Background()
+			ctx := fire.WithLimits(r.Context(), fire.DefaultLimits)
+			parsed, err := fire.Expand(ctx, parsed, fire.Globals())
+			if err != nil {
+				return unauthorized(r)
+			}
 			scope := fire.Scope(
 				ctx,
 				fire.Globals(),
 				[2]fire.Value{fire.String("key"), fire.String(user)},
 				[2]fire.Value{fire.String("secret"), fire.String(pass)},
 				[2]fire.Value{fire.String("api"), fire.String(apiName(r))},
+				[2]fire.Value{fire.String("ip"), clientIP(r)},
 			)
 			result := fire.Eval(ctx, parsed, scope)
 			if b, ok := result.Bool(ctx); b && ok {
@@ -48,6 +58,18 @@ func BasicAuth(s Store, authorized, unauthorized func(r *http.Request) Store) fu
 	}
 }
 
+// clientIP is the address of the client making the request
+func clientIP(r *http.Request) fire.Value {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return fire.IP(ip)
+	}
+	return fire.Error("unknown client ip")
+}
+
 // SetBasicAuthInfo sets the basic auth password for the provided user
 func SetBasicAuthInfo(s Store, user, password string) {
 	encoded, err := json.Marshal(password)
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rameshvk/fig/pkg/fig"
+	"github.com/rameshvk/fig/pkg/fire"
+)
+
+// handleEval evaluates the config entry for the key with the JSON
+// request body as `it`.  With `explain=true`, the response also has
+// the trace of all the sub-expressions evaluated.  The current time
+// can be pinned with `now=<RFC3339 time>`.
+func handleEval(s Store, w http.ResponseWriter, r *http.Request) interface{} {
+	var arg interface{}
+	if err := json.NewDecoder(r.Body).Decode(&arg); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	var opts fig.Options
+	if now := r.URL.Query().Get("now"); now != "" {
+		t, err := time.Parse(time.RFC3339, now)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return map[string]interface{}{"error": err.Error()}
+		}
+		opts.Clock = func() time.Time { return t }
+	}
+
+	cfg := fig.ConfigWithOptions(s, opts).(fig.Explainer)
+	result, steps, err := cfg.Explain(mux.Vars(r)["key"], arg)
+	if err == fig.ErrConfigNotFound {
+		w.WriteHeader(http.StatusNotFound)
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	response := map[string]interface{}{"result": toJSON(result)}
+	if err != nil {
+		response["error"] = err.Error()
+		if e, ok := err.(*fire.EvalError); ok && e.Location != nil {
+			response["location"] = e.Location
+			response["stack"] = e.Stack
+		}
+	}
+	if r.URL.Query().Get("explain") == "true" {
+		ctx := context.Background()
+		trace := []interface{}{}
+		for _, step := range steps {
+			trace = append(trace, map[string]interface{}{
+				"op":     step.Op,
+				"start":  step.Start,
+				"end":    step.End,
+				"depth":  step.Depth,
+				"result": toJSON(fire.ToNative(ctx, step.Result)),
+			})
+		}
+		response["trace"] = trace
+	}
+	return response
+}
+
+// toJSON converts the result of fire.ToNative into something that
+// can be encoded as JSON
+func toJSON(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, string, float64, bool:
+		return v
+	case *fire.EvalError:
+		return map[string]interface{}{"error": v.Message, "location": v.Location}
+	case error:
+		return map[string]interface{}{"error": v.Error()}
+	case map[interface{}]interface{}:
+		values := map[string]interface{}{}
+		for k, vv := range v {
+			values[fmt.Sprint(toJSON(k))] = toJSON(vv)
+		}
+		return values
+	}
+
+	// native Go values are encoded by encoding/json if possible
+	if _, err := json.Marshal(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return v
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"strconv"
 
 	"github.com/go-redis/redis"
 )
@@ -59,6 +60,36 @@ func (r red) History(key, epoch string) (string, []string) {
 	return pair[0].(string), result
 }
 
+func (r red) AddExposures(counts map[string]map[string]int) {
+	_, err := r.Client.Pipelined(func(p redis.Pipeliner) error {
+		for key, values := range counts {
+			for val, count := range values {
+				p.HIncrBy(r.prefix+"_exposures"+key, val, int64(count))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (r red) Exposures(key string) map[string]int {
+	counts, err := r.Client.HGetAll(r.prefix + "_exposures" + key).Result()
+	if err != nil {
+		panic(err)
+	}
+	result := map[string]int{}
+	for val, count := range counts {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			panic(err)
+		}
+		result[val] = n
+	}
+	return result
+}
+
 var luaCommon = `
   local prefix = KEYS[1]
   local keyVersions = prefix.."_versions"
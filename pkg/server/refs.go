@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/rameshvk/fig/pkg/fig"
+)
+
+// checkCycles verifies that the new value of the config entry does
+// not introduce a cycle of references via `config.get` (see
+// fig.Dependencies)
+func checkCycles(s Store, key, source string) error {
+	_, configs := s.GetSince(-1)
+	deps := func(k string) []string {
+		v := configs[k]
+		if k == key {
+			v = source
+		}
+		if expanded, ok := compile(v); ok {
+			return fig.Dependencies(expanded)
+		}
+		return nil
+	}
+
+	visited := map[string]bool{}
+	var path []string
+	var visit func(k string) bool
+	visit = func(k string) bool {
+		path = append(path, k)
+		for _, dep := range deps(k) {
+			if dep == key {
+				path = append(path, dep)
+				return true
+			}
+			if !visited[dep] {
+				visited[dep] = true
+				if visit(dep) {
+					return true
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		return false
+	}
+	if visit(key) {
+		return errors.New("dependency cycle: " + strings.Join(path, " -> "))
+	}
+	return nil
+}
+
+// overlay is a store with the value of one key replaced.  It is used
+// to evaluate changes before they are saved.
+type overlay struct {
+	Store
+	key, source string
+}
+
+func (o overlay) GetSince(version int) (int, map[string]string) {
+	ver, configs := o.Store.GetSince(version)
+	result := map[string]string{o.key: o.source}
+	for k, v := range configs {
+		if k != o.key {
+			result[k] = v
+		}
+	}
+	return ver, result
+}
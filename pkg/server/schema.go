@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/rameshvk/fig/pkg/fig"
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/schema"
+)
+
+// handleSetSchema sets the schema for a config entry (see package
+// schema).  The current value of the entry must match the new
+// schema.
+func handleSetSchema(s Store, decoded interface{}, w http.ResponseWriter, r *http.Request) interface{} {
+	val, err := json.Marshal(decoded)
+	if err != nil {
+		panic(err)
+	}
+	sch, err := schema.Parse(string(val))
+	if err == nil {
+		_, configs := s.GetSince(-1)
+		key := strings.TrimPrefix(mux.Vars(r)["key"], schema.Prefix)
+		if source, ok := configs[key]; ok {
+			err = verify(s, key, source, sch)
+		}
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return map[string]interface{}{"error": err.Error()}
+	}
+	s.Set(mux.Vars(r)["key"], string(val))
+	return nil
+}
+
+// checkSchema verifies a new value of the config entry against its
+// schema, if there is one
+func checkSchema(s Store, key, source string) error {
+	_, configs := s.GetSince(-1)
+	v, ok := configs[schema.Key(key)]
+	if !ok {
+		return nil
+	}
+	sch, err := schema.Parse(v)
+	if err != nil {
+		return fmt.Errorf("invalid schema: %v", err)
+	}
+	return verify(s, key, source, sch)
+}
+
+// verify checks the results of a config entry against the schema.
+//
+// The values of the entry are inferred statically where possible
+// (see fire.Infer) and the entry is also evaluated with each of the
+// sample inputs of the schema.  Entries which are not fig
+// expressions are validated as JSON values.
+func verify(s Store, key, source string, sch *schema.Schema) error {
+	expanded, ok := compile(source)
+	if !ok {
+		var v interface{}
+		if err := json.Unmarshal([]byte(source), &v); err != nil {
+			return nil
+		}
+		if err := sch.Validate(v); err != nil {
+			return fmt.Errorf("value does not match schema: %v", err)
+		}
+		return nil
+	}
+
+	ctx := fire.WithLimits(context.Background(), fire.DefaultLimits)
+	values, _ := fire.Infer(ctx, expanded, fire.Globals())
+	for _, v := range values {
+		if err := sch.Validate(fire.ToNative(ctx, v)); err != nil {
+			return fmt.Errorf("value %s does not match schema: %v", v.Code(ctx), err)
+		}
+	}
+
+	cfg := fig.ConfigWithStore(overlay{s, key, source})
+	for _, input := range sch.Inputs {
+		result, err := cfg.Get(key, input)
+		encoded, _ := json.Marshal(input)
+		if err != nil {
+			return fmt.Errorf("failed for input %s: %v", encoded, err)
+		}
+		if err := sch.Validate(result); err != nil {
+			return fmt.Errorf("result for input %s does not match schema: %v", encoded, err)
+		}
+	}
+	return nil
+}
@@ -4,14 +4,23 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
+
+	"github.com/rameshvk/fig/pkg/fire"
+	"github.com/rameshvk/fig/pkg/parse"
+	"github.com/rameshvk/fig/pkg/schema"
 )
 
 // Store is the storage interface for the server.  See NewReids/Store
@@ -38,13 +47,27 @@ type Store interface {
 func Handler(s func(r *http.Request) Store) http.Handler {
 	m := mux.NewRouter()
 
-	m.Handle("/items", wrap(s, handleGetSince)).Methods("GET")
-	m.Handle("/items/{key}", wrap(s, handleSet)).Methods("POST")
-	m.Handle("/items/{key}", wrap(s, handleHistory)).Methods("GET")
+	m.Handle("/items", wrap(s, handleGetSince)).Methods("GET").Name("GetSince")
+	m.Handle("/items/{key}", wrap(s, handleSet)).Methods("POST").Name("Set")
+	m.Handle("/items/{key}", wrap(s, handleHistory)).Methods("GET").Name("History")
+	m.Handle("/exposures", wrap(s, handleExpose)).Methods("POST").Name("Expose")
+	m.Handle("/exposures/{key}", wrap(s, handleExposures)).Methods("GET").Name("Exposures")
+	m.Handle("/eval/{key}", wrap(s, handleEval)).Methods("POST").Name("Eval")
 
 	return m
 }
 
+// ExposureStore is implemented by stores which can aggregate
+// exposure events.  The exposures endpoints are only available if
+// the store implements this interface.
+type ExposureStore interface {
+	// AddExposures increments the counts for each key and value
+	AddExposures(counts map[string]map[string]int)
+
+	// Exposures returns the counts for each value of the key
+	Exposures(key string) map[string]int
+}
+
 func handleGetSince(s Store, w http.ResponseWriter, r *http.Request) interface{} {
 	ver := -1
 	if n, err := strconv.Atoi(r.URL.Query().Get("version")); err == nil {
@@ -54,12 +77,35 @@ func handleGetSince(s Store, w http.ResponseWriter, r *http.Request) interface{}
 	return map[string]interface{}{"version": ver, "config": config}
 }
 
+// handleSet saves a config entry.  The body is normally a JSON
+// value, which is also a valid fig expression.  Other fig
+// expressions can be saved by posting their source as text/plain.
+//
+// Entries are rejected if they fail the static checks (see check),
+// introduce reference cycles or do not match their schema.
 func handleSet(s Store, w http.ResponseWriter, r *http.Request) interface{} {
+	key := mux.Vars(r)["key"]
+	if isSource(r) && !strings.HasPrefix(key, schema.Prefix) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return map[string]interface{}{"error": err.Error()}
+		}
+		if _, err := parseSource(string(body)); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return setSource(s, w, key, string(body))
+	}
+
 	var decoded interface{}
 	if err := json.NewDecoder(r.Body).Decode(&decoded); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return map[string]interface{}{"error": err.Error()}
 	}
+	if strings.HasPrefix(key, schema.Prefix) {
+		return handleSetSchema(s, decoded, w, r)
+	}
 	if err := isValid(decoded); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		return map[string]interface{}{"error": err.Error()}
@@ -68,15 +114,79 @@ func handleSet(s Store, w http.ResponseWriter, r *http.Request) interface{} {
 	if err != nil {
 		panic(err)
 	}
-	s.Set(mux.Vars(r)["key"], string(val))
+	return setSource(s, w, key, string(val))
+}
+
+// setSource checks and saves the source of a config entry
+func setSource(s Store, w http.ResponseWriter, key, source string) interface{} {
+	if err := check(source); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return map[string]interface{}{"error": err.Error(), "location": err.Location}
+	}
+	if err := checkCycles(s, key, source); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return map[string]interface{}{"error": err.Error()}
+	}
+	if err := checkSchema(s, key, source); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return map[string]interface{}{"error": err.Error()}
+	}
+	s.Set(key, source)
 	return nil
 }
 
+// isSource checks if the body of the request is fig source rather
+// than JSON
+func isSource(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "text/plain"
+}
+
 func handleHistory(s Store, w http.ResponseWriter, r *http.Request) interface{} {
 	epoch, history := s.History(mux.Vars(r)["key"], r.URL.Query().Get("epoch"))
 	return map[string]interface{}{"epoch": epoch, "history": history}
 }
 
+func handleExpose(s Store, w http.ResponseWriter, r *http.Request) interface{} {
+	es, ok := s.(ExposureStore)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return map[string]interface{}{"error": "exposures not supported"}
+	}
+
+	var events []struct {
+		Key    string
+		Result interface{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&events); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return map[string]interface{}{"error": err.Error()}
+	}
+
+	counts := map[string]map[string]int{}
+	for _, e := range events {
+		val, err := json.Marshal(e.Result)
+		if err != nil {
+			panic(err)
+		}
+		if counts[e.Key] == nil {
+			counts[e.Key] = map[string]int{}
+		}
+		counts[e.Key][string(val)]++
+	}
+	es.AddExposures(counts)
+	return nil
+}
+
+func handleExposures(s Store, w http.ResponseWriter, r *http.Request) interface{} {
+	es, ok := s.(ExposureStore)
+	if !ok {
+		w.WriteHeader(http.StatusNotImplemented)
+		return map[string]interface{}{"error": "exposures not supported"}
+	}
+	return map[string]interface{}{"counts": es.Exposures(mux.Vars(r)["key"])}
+}
+
 func wrap(s func(r *http.Request) Store, fn func(s Store, w http.ResponseWriter, r *http.Request) interface{}) http.Handler {
 	f := func(w http.ResponseWriter, r *http.Request) {
 		store := s(r)
@@ -118,15 +228,57 @@ func isValid(v interface{}) error {
 	return errors.New("unexpected type")
 }
 
+// check statically checks the constraints in a config entry (see
+// fire.Check).  Entries which cannot be parsed are not checked.
+func check(source string) *fire.EvalError {
+	expanded, ok := compile(source)
+	if !ok {
+		return nil
+	}
+	ctx := fire.WithLimits(context.Background(), fire.DefaultLimits)
+	if errs := fire.Check(ctx, expanded, fire.Globals()); len(errs) > 0 {
+		return errs[0].(*fire.EvalError)
+	}
+	return nil
+}
+
+// compile parses and expands a config entry.  It returns false if
+// the entry is not a valid fig expression.
+func compile(source string) (expanded interface{}, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			expanded, ok = nil, false
+		}
+	}()
+
+	parsed, err := parseSource(source)
+	if err != nil {
+		return nil, false
+	}
+	ctx := fire.WithLimits(context.Background(), fire.DefaultLimits)
+	expanded, err = fire.Expand(ctx, parsed, fire.Globals())
+	return expanded, err == nil
+}
+
+// parseSource parses a config entry, converting parser panics into
+// errors
+func parseSource(source string) (parsed interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			parsed, err = nil, fmt.Errorf("%v", r)
+		}
+	}()
+
+	parsed, errs := parse.String(source)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	return parsed, nil
+}
+
 func apiName(r *http.Request) string {
-	_, ok := mux.Vars(r)["key"]
-	switch {
-	case ok && r.Method == http.MethodGet:
-		return "History"
-	case ok && r.Method == http.MethodPost:
-		return "Set"
-	case r.Method == http.MethodGet:
-		return "GetSince"
+	if route := mux.CurrentRoute(r); route != nil {
+		return route.GetName()
 	}
 	return ""
 }
@@ -5,10 +5,12 @@ import (
 	"github.com/rameshvk/fig/pkg/fig"
 	"github.com/rameshvk/fig/pkg/server"
 
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -46,6 +48,7 @@ func TestAuthorizedHandler(t *testing.T) {
 	suite := Suite{fig.New(ts.URL).WithKey("authorized_key", "secret")}
 	suite.Run(t)
 	t.Run("MalformedJSON", suite.testMalformedJSON)
+	t.Run("SetList", suite.testSetList)
 }
 
 func TestUnauthorizedHandler(t *testing.T) {
@@ -131,6 +134,171 @@ func TestUnauthorizedHandlerWrongKey(t *testing.T) {
 	})
 }
 
+func TestBasicAuthClientIP(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal("mini redis failed", err)
+	}
+	defer s.Close()
+
+	store := server.NewRedisStore(s.Addr(), "test-handler")
+	authStore := server.NewRedisStore(s.Addr(), "auth-store")
+	unauthorized := func(r *http.Request) server.Store {
+		return nil
+	}
+	authorized := func(r *http.Request) server.Store {
+		return store
+	}
+
+	ts := httptest.NewServer(server.Handler(server.BasicAuth(authStore, authorized, unauthorized)))
+	defer ts.Close()
+
+	authStore.Set("auth:basic:office", `secret == "s" & ip.inCIDR(list("127.0.0.0/8", "::1/128"))`)
+	authStore.Set("auth:basic:remote", `secret == "s" & ip.inCIDR("10.0.0.0/8")`)
+
+	if ver, _ := fig.New(ts.URL).WithKey("office", "s").GetSince(-1); ver != -1 {
+		t.Error("Unexpected version", ver)
+	}
+
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("did not panic")
+		}
+	}()
+	fig.New(ts.URL).WithKey("remote", "s").GetSince(-1)
+}
+
+func TestSchema(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal("mini redis failed", err)
+	}
+	defer s.Close()
+
+	store := server.NewRedisStore(s.Addr(), "test-schema")
+	ts := httptest.NewServer(server.Handler(func(r *http.Request) server.Store {
+		return store
+	}))
+	defer ts.Close()
+
+	set := func(key, value string) string {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/items/"+key, "application/json", strings.NewReader(value))
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer resp.Body.Close()
+		var result struct{ Error string }
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && resp.StatusCode != http.StatusOK {
+			t.Fatal("Unexpected response", resp.StatusCode, err)
+		}
+		return result.Error
+	}
+
+	if err := set("schema:ratio", `{"type": "number", "maximum": 1}`); err != "" {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := set("ratio", `0.5`); err != "" {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := set("ratio", `"0.5"`); err != `value "0.5" does not match schema: expected number, got string` {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := set("ratio", `[1]`); err != "value does not match schema: expected number, got array" {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := set("schema:ratio", `{"type": "string"}`); err != "value 0.5 does not match schema: expected string, got number" {
+		t.Fatal("Unexpected error", err)
+	}
+	if err := set("schema:ratio", `[]`); err != "schema must be an object" {
+		t.Fatal("Unexpected error", err)
+	}
+
+	// entries which depend on it are checked with the sample inputs
+	store.Set("beta", `if(it.beta, it.ratio, 0)`)
+	if err := set("schema:beta", `{"type": "number", "inputs": [{"beta": false}, {"beta": true, "ratio": 0.5}]}`); err != "" {
+		t.Fatal("Unexpected error", err)
+	}
+	err2 := set("schema:beta", `{"type": "number", "inputs": [{"beta": true, "ratio": "x"}]}`)
+	if err2 != `result for input {"beta":true,"ratio":"x"} does not match schema: expected number, got string` {
+		t.Fatal("Unexpected error", err2)
+	}
+
+	// sample inputs can use other entries
+	store.Set("ref", `config.get("beta", it) * 2`)
+	if err := set("schema:ref", `{"maximum": 1, "inputs": [{"beta": true, "ratio": 0.5}]}`); err != "" {
+		t.Fatal("Unexpected error", err)
+	}
+	err2 = set("schema:ref", `{"maximum": 1, "inputs": [{"beta": true, "ratio": 0.75}]}`)
+	if err2 != `result for input {"beta":true,"ratio":0.75} does not match schema: 1.5 is more than 1` {
+		t.Fatal("Unexpected error", err2)
+	}
+
+	err2 = set("schema:beta", `{"type": "number", "inputs": [{"beta": true}]}`)
+	if err2 != `failed for input {"beta":true}: field not found: "ratio" (at 14:15)` {
+		t.Fatal("Unexpected error", err2)
+	}
+}
+
+func TestSetSource(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal("mini redis failed", err)
+	}
+	defer s.Close()
+
+	store := server.NewRedisStore(s.Addr(), "test-source")
+	ts := httptest.NewServer(server.Handler(func(r *http.Request) server.Store {
+		return store
+	}))
+	defer ts.Close()
+
+	set := func(key, source string) (int, map[string]interface{}) {
+		t.Helper()
+		resp, err := http.Post(ts.URL+"/items/"+key, "text/plain; charset=utf-8", strings.NewReader(source))
+		if err != nil {
+			t.Fatal("Unexpected error", err)
+		}
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil && resp.StatusCode != http.StatusOK {
+			t.Fatal("Unexpected response", resp.StatusCode, err)
+		}
+		return resp.StatusCode, result
+	}
+
+	if code, result := set("b", `config.get("a", it)`); code != http.StatusOK {
+		t.Fatal("Unexpected result", code, result)
+	}
+	if _, config := store.GetSince(-1); config["b"] != `config.get("a", it)` {
+		t.Error("Unexpected config", config)
+	}
+
+	code, result := set("a", `config.get("b", it)`)
+	if code != http.StatusBadRequest || result["error"] != "dependency cycle: a -> b -> a" {
+		t.Error("Unexpected result", code, result)
+	}
+	if _, config := store.GetSince(-1); config["a"] != "" {
+		t.Error("Cycle was saved", config)
+	}
+
+	for _, source := range []string{`config.get("b", `, `1, 2`} {
+		if code, result := set("a", source); code != http.StatusBadRequest || result["error"] == nil {
+			t.Error("Unexpected result", source, code, result)
+		}
+	}
+
+	// constraints are checked statically
+	code, result = set("ratio", `list(unit(2), where(unit = constraint({ it >= 0 & it <= 1 })))`)
+	location := map[string]interface{}{"start": 9.0, "end": 9.0}
+	if code != http.StatusBadRequest || !reflect.DeepEqual(result["location"], location) || !strings.HasPrefix(result["error"].(string), "2 does not satisfy") {
+		t.Error("Unexpected result", code, result)
+	}
+	if code, result := set("ratio", `list(unit(0.5), where(unit = constraint({ it >= 0 & it <= 1 })))`); code != http.StatusOK {
+		t.Error("Unexpected result", code, result)
+	}
+}
+
 type Suite struct {
 	server.Store
 }
@@ -183,6 +351,15 @@ func (s Suite) testHistory(t *testing.T) {
 	}
 }
 
+// testSetList checks values which are not fig expressions can still
+// be set
+func (s Suite) testSetList(t *testing.T) {
+	s.Set("boos", `["hoo","woo"]`)
+	if _, config := s.GetSince(-1); config["boos"] != `["hoo","woo"]` {
+		t.Error("Unexpected result", config)
+	}
+}
+
 func (s Suite) testMalformedJSON(t *testing.T) {
 	mustPanic := func(cause string, fn func()) {
 		defer func() {
@@ -202,3 +379,81 @@ func (s Suite) testMalformedJSON(t *testing.T) {
 		s.Set("boo", "{}")
 	})
 }
+
+func TestEval(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatal("mini redis failed", err)
+	}
+	defer s.Close()
+
+	store := server.NewRedisStore(s.Addr(), "test-eval")
+	store.Set("boo", `if(it.age > 10, "old", "young")`)
+	store.Set("bad", `it.name.first`)
+	store.Set("launched", `time.now() > time.parse("2019-10-07T09:00:00Z")`)
+	store.Set("segment", `it.age > 18`)
+	store.Set("adult", `if(config.get("segment", it), "adult", "minor")`)
+	ts := httptest.NewServer(server.Handler(func(r *http.Request) server.Store {
+		return store
+	}))
+	defer ts.Close()
+
+	eval := func(path, body string) map[string]interface{} {
+		resp, err := http.Post(ts.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			t.Fatal("post failed", err)
+		}
+		defer resp.Body.Close()
+		var result map[string]interface{}
+		if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+			t.Fatal("decode failed", err)
+		}
+		return result
+	}
+
+	result := eval("/eval/boo", `{"age": 5}`)
+	if !reflect.DeepEqual(result, map[string]interface{}{"result": "young"}) {
+		t.Error("Unexpected result", result)
+	}
+
+	result = eval("/eval/boo?explain=true", `{"age": 20}`)
+	trace, _ := result["trace"].([]interface{})
+	if result["result"] != "old" || len(trace) != 5 {
+		t.Fatal("Unexpected result", result)
+	}
+	expected := map[string]interface{}{
+		"op":     ">",
+		"start":  10.0,
+		"end":    11.0,
+		"depth":  1.0,
+		"result": true,
+	}
+	if !reflect.DeepEqual(trace[2], expected) {
+		t.Error("Unexpected step", trace[2])
+	}
+
+	result = eval("/eval/bad", `{"name": 5}`)
+	location := map[string]interface{}{"start": 7.0, "end": 8.0}
+	if result["error"] != "cannot lookup a number (at 7:8)" || !reflect.DeepEqual(result["location"], location) {
+		t.Error("Unexpected result", result)
+	}
+
+	result = eval("/eval/launched?now=2019-10-07T10:00:00Z", `{}`)
+	if result["result"] != true {
+		t.Error("Unexpected result", result)
+	}
+	result = eval("/eval/launched?now=2019-10-07T08:00:00Z", `{}`)
+	if result["result"] != false {
+		t.Error("Unexpected result", result)
+	}
+
+	result = eval("/eval/adult", `{"age": 20}`)
+	if result["result"] != "adult" {
+		t.Error("Unexpected result", result)
+	}
+
+	result = eval("/eval/missing", `{}`)
+	if result["error"] != "config not found" {
+		t.Error("Unexpected result", result)
+	}
+}